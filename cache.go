@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// oldEditorCachePath is where the cache was stored before it moved to the user cache folder
+const oldEditorCachePath = "modpackEditorCache.bin"
+
+// editorCachePath is the file the editor cache is stored in
+var editorCachePath string
+
+// defaultEditorCachePath returns the cache file in the user cache folder,
+// or the working directory if there isn't one
+func defaultEditorCachePath() string {
+	folder, err := os.UserCacheDir()
+	if err != nil {
+		return oldEditorCachePath
+	}
+	return filepath.Join(folder, "modpack-editor", "modpackEditorCache.bin")
+}
+
+// moveOldEditorCache moves a cache left in the working directory by older versions to editorCachePath,
+// unless there is already a cache there
+func moveOldEditorCache() {
+	oldPath, err := filepath.Abs(oldEditorCachePath)
+	if err != nil {
+		return
+	}
+	newPath, err := filepath.Abs(editorCachePath)
+	if err != nil || oldPath == newPath {
+		return
+	}
+	if _, err := os.Stat(oldPath); err != nil {
+		return
+	}
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(newPath), 0755)
+	if err == nil {
+		err = moveFile(oldPath, newPath)
+	}
+	if err != nil {
+		log.Print("Error moving cache to the user cache folder:")
+		log.Print(err)
+		return
+	}
+	log.Printf("Moved cache to %s", newPath)
+}
+
+// moveFile renames a file, copying it if it is moved to another drive
+func moveFile(oldPath, newPath string) error {
+	if os.Rename(oldPath, newPath) == nil {
+		return nil
+	}
+
+	in, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(newPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	in.Close()
+	return os.Remove(oldPath)
+}
+
+// cacheMigrations upgrade a cache from the version they are keyed by to the next version
+var cacheMigrations = map[int]func(cache *ModpackEditorCache){
+	// Version 4 replaced LastOpenedModpack with OpenModpacks
+	3: func(cache *ModpackEditorCache) {
+		if len(cache.OpenModpacks) == 0 && len(cache.LastOpenedModpack) > 0 {
+			cache.OpenModpacks = []string{cache.LastOpenedModpack}
+		}
+		cache.LastOpenedModpack = ""
+	},
+}
+
+// migrateEditorCache upgrades a loaded cache to CurrentCacheVersion.
+// It returns false if the cache can't be used, and should be discarded.
+func migrateEditorCache(cache *ModpackEditorCache) bool {
+	if cache.CacheVersion > CurrentCacheVersion {
+		log.Printf("Cache is from a newer version of modpack-editor (version %d), discarding", cache.CacheVersion)
+		return false
+	}
+
+	migrated := false
+	for cache.CacheVersion < CurrentCacheVersion {
+		migrate, ok := cacheMigrations[cache.CacheVersion]
+		if !ok {
+			log.Printf("Cache version %d is too old to migrate, discarding", cache.CacheVersion)
+			return false
+		}
+		migrate(cache)
+		cache.CacheVersion++
+		migrated = true
+	}
+
+	if migrated {
+		log.Printf("Migrated cache to version %d", CurrentCacheVersion)
+		// Write it in the new format
+		markCacheChanged()
+	}
+	return true
+}
+
+// cacheQuietPeriod is how long the cache must go without changes before it is written
+const cacheQuietPeriod = 5 * time.Second
+
+// cacheMaxDelay is the longest a change waits to be written while the cache keeps changing
+const cacheMaxDelay = time.Minute
+
+// cacheWriter tracks changes to the editor cache, so it is only written when something changed
+var cacheWriter struct {
+	mutex sync.Mutex
+	// changes is incremented on every change, written is its value at the last write
+	changes int
+	written int
+	// changed wakes the background writer
+	changed chan struct{}
+	// writeMutex makes sure only one write happens at a time
+	writeMutex sync.Mutex
+}
+
+func init() {
+	cacheWriter.changed = make(chan struct{}, 1)
+}
+
+// markCacheChanged tells the background writer that the cache needs writing
+func markCacheChanged() {
+	cacheWriter.mutex.Lock()
+	cacheWriter.changes++
+	cacheWriter.mutex.Unlock()
+
+	select {
+	case cacheWriter.changed <- struct{}{}:
+	default:
+		// Already woken
+	}
+}
+
+// flushEditorCache writes the cache now, if it changed since it was last written
+func flushEditorCache() {
+	cacheWriter.writeMutex.Lock()
+	defer cacheWriter.writeMutex.Unlock()
+
+	cacheWriter.mutex.Lock()
+	changes := cacheWriter.changes
+	cacheWriter.mutex.Unlock()
+	if changes == cacheWriter.written {
+		return
+	}
+
+	writeEditorCache()
+	for i := 0; i < maxEvictionWrites && evictToFit(); i++ {
+		writeEditorCache()
+	}
+	cacheWriter.written = changes
+}
+
+// runCacheWriter writes the cache once it stops changing, until stop is closed.
+// The cache should be flushed after it returns.
+func runCacheWriter(stop chan struct{}) {
+	var quiet, maxDelay <-chan time.Time
+	for {
+		select {
+		case <-cacheWriter.changed:
+			quiet = time.After(cacheQuietPeriod)
+			if maxDelay == nil {
+				maxDelay = time.After(cacheMaxDelay)
+			}
+			continue
+		case <-quiet:
+		case <-maxDelay:
+		case <-stop:
+			return
+		}
+
+		flushEditorCache()
+		quiet, maxDelay = nil, nil
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrateEditorCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      int
+		lastOpened   string
+		openModpacks []string
+		wantOK       bool
+		wantOpen     []string
+	}{
+		{"current", CurrentCacheVersion, "", []string{"pack"}, true, []string{"pack"}},
+		{"version 3", 3, "pack", nil, true, []string{"pack"}},
+		{"version 3 with nothing open", 3, "", nil, true, nil},
+		{"version 3 with open modpacks", 3, "old", []string{"a", "b"}, true, []string{"a", "b"}},
+		{"too old", 2, "pack", nil, false, nil},
+		{"newer", CurrentCacheVersion + 1, "", nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &ModpackEditorCache{
+				CacheVersion:      tt.version,
+				LastOpenedModpack: tt.lastOpened,
+				OpenModpacks:      tt.openModpacks,
+			}
+			ok := migrateEditorCache(cache)
+			if ok != tt.wantOK {
+				t.Fatalf("got %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if cache.CacheVersion != CurrentCacheVersion {
+				t.Errorf("got version %d, want %d", cache.CacheVersion, CurrentCacheVersion)
+			}
+			if strings.Join(cache.OpenModpacks, ",") != strings.Join(tt.wantOpen, ",") {
+				t.Errorf("got open modpacks %q, want %q", cache.OpenModpacks, tt.wantOpen)
+			}
+			if len(cache.LastOpenedModpack) > 0 {
+				t.Errorf("LastOpenedModpack is still %q", cache.LastOpenedModpack)
+			}
+		})
+	}
+}
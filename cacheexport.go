@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cacheExportFormat identifies cache export files
+const cacheExportFormat = "modpack-editor-cache"
+
+// cacheExportVersion is the version of the export format. Newer files can't be imported.
+const cacheExportVersion = 1
+
+// cacheExportHeader is the first line of a cache export
+type cacheExportHeader struct {
+	Format  string
+	Version int
+}
+
+// cacheExportEntry is every other line of a cache export. Type is mod, file or slug.
+type cacheExportEntry struct {
+	Type string
+	Mod  *AddonData `json:",omitempty"`
+	File *FileData  `json:",omitempty"`
+	Slug string     `json:",omitempty"`
+	ID   int        `json:",omitempty"`
+}
+
+// CacheImportCounts counts the entries added or replaced by an import
+type CacheImportCounts struct {
+	Mods    int
+	Files   int
+	SlugIDs int
+}
+
+// exportEditorCache writes the cached projects, files and slug IDs as gzipped JSON lines
+func exportEditorCache(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	encoder := json.NewEncoder(zw)
+	err := encoder.Encode(cacheExportHeader{cacheExportFormat, cacheExportVersion})
+	if err != nil {
+		return err
+	}
+
+	mainCache.cachedModsMutex.RLock()
+	for _, v := range mainCache.CachedMods {
+		mod := v
+		err = encoder.Encode(cacheExportEntry{Type: "mod", Mod: &mod})
+		if err != nil {
+			break
+		}
+	}
+	mainCache.cachedModsMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	mainCache.cachedFilesMutex.RLock()
+	for _, v := range mainCache.CachedFiles {
+		file := v
+		err = encoder.Encode(cacheExportEntry{Type: "file", File: &file})
+		if err != nil {
+			break
+		}
+	}
+	mainCache.cachedFilesMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	mainCache.cachedSlugIDsMutex.RLock()
+	for k, v := range mainCache.CachedSlugIDs {
+		err = encoder.Encode(cacheExportEntry{Type: "slug", Slug: k, ID: v})
+		if err != nil {
+			break
+		}
+	}
+	mainCache.cachedSlugIDsMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// importEditorCache merges an export into the cache. Where both have a project or file,
+// the one queried most recently is kept.
+func importEditorCache(r io.Reader) (CacheImportCounts, error) {
+	var counts CacheImportCounts
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return counts, err
+	}
+	decoder := json.NewDecoder(bufio.NewReader(zr))
+
+	var header cacheExportHeader
+	err = decoder.Decode(&header)
+	if err != nil {
+		return counts, err
+	}
+	if header.Format != cacheExportFormat {
+		return counts, errors.New("Not a modpack-editor cache export")
+	}
+	if header.Version > cacheExportVersion {
+		return counts, fmt.Errorf("Cache export version %d is newer than this version of modpack-editor supports", header.Version)
+	}
+
+	// Changes made before an error are kept, as every entry is complete by itself
+	defer func() {
+		if counts.Mods > 0 || counts.Files > 0 || counts.SlugIDs > 0 {
+			markCacheChanged()
+		}
+	}()
+
+	for {
+		var entry cacheExportEntry
+		err = decoder.Decode(&entry)
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, err
+		}
+
+		switch entry.Type {
+		case "mod":
+			if entry.Mod == nil || entry.Mod.ID == 0 {
+				continue
+			}
+			mainCache.cachedModsMutex.Lock()
+			existing, ok := mainCache.CachedMods[entry.Mod.ID]
+			if !ok || entry.Mod.LastQueried.After(existing.LastQueried) {
+				if trimCachedMods {
+					mainCache.CachedMods[entry.Mod.ID] = entry.Mod.trimmed()
+				} else {
+					mainCache.CachedMods[entry.Mod.ID] = *entry.Mod
+				}
+				counts.Mods++
+			}
+			mainCache.cachedModsMutex.Unlock()
+		case "file":
+			if entry.File == nil || entry.File.ID == 0 {
+				continue
+			}
+			mainCache.cachedFilesMutex.Lock()
+			existing, ok := mainCache.CachedFiles[entry.File.ID]
+			if !ok || entry.File.LastQueried.After(existing.LastQueried) {
+				mainCache.CachedFiles[entry.File.ID] = *entry.File
+				counts.Files++
+			}
+			mainCache.cachedFilesMutex.Unlock()
+		case "slug":
+			if len(entry.Slug) == 0 || entry.ID == 0 {
+				continue
+			}
+			// Slug IDs never change, so existing ones are kept
+			mainCache.cachedSlugIDsMutex.Lock()
+			if _, ok := mainCache.CachedSlugIDs[entry.Slug]; !ok {
+				mainCache.CachedSlugIDs[entry.Slug] = entry.ID
+				counts.SlugIDs++
+			}
+			mainCache.cachedSlugIDsMutex.Unlock()
+		default:
+			// Skip entry types added by newer versions
+		}
+	}
+}
+
+func cacheExportCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: modpack-editor cache export <file>")
+		fmt.Fprintln(os.Stderr, "Use - to write to standard output.")
+		return 2
+	}
+
+	if args[0] == "-" {
+		err := exportEditorCache(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting cache: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	file, err := os.Create(args[0])
+	if err == nil {
+		err = exportEditorCache(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting cache: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func cacheImportCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: modpack-editor cache import <file>")
+		fmt.Fprintln(os.Stderr, "Use - to read from standard input.")
+		return 2
+	}
+
+	var counts CacheImportCounts
+	var err error
+	if args[0] == "-" {
+		counts, err = importEditorCache(os.Stdin)
+	} else {
+		var file *os.File
+		file, err = os.Open(args[0])
+		if err == nil {
+			counts, err = importEditorCache(file)
+			file.Close()
+		}
+	}
+	fmt.Printf("Imported %d projects, %d files and %d slug IDs\n", counts.Mods, counts.Files, counts.SlugIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing cache: %v\n", err)
+		return 1
+	}
+	return 0
+}
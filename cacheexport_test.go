@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// setTestCache replaces the cached mods, files and slug IDs
+func setTestCache(mods map[int]AddonData, files map[int]FileData, slugIDs map[string]int) {
+	mainCache.CachedMods = mods
+	mainCache.CachedFiles = files
+	mainCache.CachedSlugIDs = slugIDs
+}
+
+func TestImportEditorCacheKeepsNewest(t *testing.T) {
+	defer setTestCache(mainCache.CachedMods, mainCache.CachedFiles, mainCache.CachedSlugIDs)
+
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	tests := []struct {
+		name         string
+		cached       *time.Time
+		imported     time.Time
+		wantImported bool
+	}{
+		{"not cached", nil, older, true},
+		{"newer import", &older, newer, true},
+		{"older import", &newer, older, false},
+		{"same time", &older, older, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Export the imported entries
+			setTestCache(
+				map[int]AddonData{1: {ID: 1, Name: "imported", LastQueried: tt.imported}},
+				map[int]FileData{10: {ID: 10, FileName: "imported.jar", LastQueried: tt.imported}},
+				map[string]int{"imported": 1},
+			)
+			var export bytes.Buffer
+			err := exportEditorCache(&export)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mods := map[int]AddonData{}
+			files := map[int]FileData{}
+			if tt.cached != nil {
+				mods[1] = AddonData{ID: 1, Name: "cached", LastQueried: *tt.cached}
+				files[10] = FileData{ID: 10, FileName: "cached.jar", LastQueried: *tt.cached}
+			}
+			setTestCache(mods, files, map[string]int{"imported": 2})
+
+			counts, err := importEditorCache(&export)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantName, wantFileName, wantCount := "cached", "cached.jar", 0
+			if tt.wantImported {
+				wantName, wantFileName, wantCount = "imported", "imported.jar", 1
+			}
+			if mainCache.CachedMods[1].Name != wantName || counts.Mods != wantCount {
+				t.Errorf("got mod %q with %d imported, want %q with %d", mainCache.CachedMods[1].Name, counts.Mods, wantName, wantCount)
+			}
+			if mainCache.CachedFiles[10].FileName != wantFileName || counts.Files != wantCount {
+				t.Errorf("got file %q with %d imported, want %q with %d", mainCache.CachedFiles[10].FileName, counts.Files, wantFileName, wantCount)
+			}
+			// Slug IDs never change, so the cached one is kept
+			if mainCache.CachedSlugIDs["imported"] != 2 || counts.SlugIDs != 0 {
+				t.Errorf("cached slug ID was replaced with %d", mainCache.CachedSlugIDs["imported"])
+			}
+		})
+	}
+}
+
+// gzipped compresses text, as it is in a cache export
+func gzipped(text string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(text))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestImportEditorCacheRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not gzipped", []byte("{}")},
+		{"empty", nil},
+		{"other format", gzipped(`{"Format": "something-else", "Version": 1}`)},
+		{"newer version", gzipped(fmt.Sprintf(`{"Format": %q, "Version": %d}`, cacheExportFormat, cacheExportVersion+1))},
+	}
+	for _, tt := range tests {
+		if _, err := importEditorCache(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: imported without an error", tt.name)
+		}
+	}
+}
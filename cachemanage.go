@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheAgeGroup counts the cached projects last queried within an age range
+type CacheAgeGroup struct {
+	Label string
+	Count int
+}
+
+// CacheStats describes what is in the editor cache
+type CacheStats struct {
+	Path          string
+	Mods          int
+	Files         int
+	SlugIDs       int
+	SizeOnDisk    int64
+	OldestQueried time.Time
+	NewestQueried time.Time
+	Ages          []CacheAgeGroup
+}
+
+// cacheAgeGroups are the ranges project ages are grouped into. The last one has no limit.
+var cacheAgeGroups = []struct {
+	Label  string
+	MaxAge time.Duration
+}{
+	{"Under 1 day", 24 * time.Hour},
+	{"1 to 2 days", 48 * time.Hour},
+	{"2 to 7 days", 7 * 24 * time.Hour},
+	{"7 to 30 days", 30 * 24 * time.Hour},
+	{"Over 30 days", 0},
+}
+
+func getEditorCacheStats() CacheStats {
+	stats := CacheStats{Path: editorCachePath}
+	if !disableCacheStore {
+		if info, err := os.Stat(editorCachePath); err == nil {
+			stats.SizeOnDisk = info.Size()
+		}
+	}
+
+	stats.Ages = make([]CacheAgeGroup, len(cacheAgeGroups))
+	for i, v := range cacheAgeGroups {
+		stats.Ages[i].Label = v.Label
+	}
+
+	now := time.Now()
+	mainCache.cachedModsMutex.RLock()
+	stats.Mods = len(mainCache.CachedMods)
+	for _, v := range mainCache.CachedMods {
+		if stats.OldestQueried.IsZero() || v.LastQueried.Before(stats.OldestQueried) {
+			stats.OldestQueried = v.LastQueried
+		}
+		if v.LastQueried.After(stats.NewestQueried) {
+			stats.NewestQueried = v.LastQueried
+		}
+		age := now.Sub(v.LastQueried)
+		for i, group := range cacheAgeGroups {
+			if group.MaxAge == 0 || age < group.MaxAge {
+				stats.Ages[i].Count++
+				break
+			}
+		}
+	}
+	mainCache.cachedModsMutex.RUnlock()
+
+	mainCache.cachedFilesMutex.RLock()
+	stats.Files = len(mainCache.CachedFiles)
+	mainCache.cachedFilesMutex.RUnlock()
+	mainCache.cachedSlugIDsMutex.RLock()
+	stats.SlugIDs = len(mainCache.CachedSlugIDs)
+	mainCache.cachedSlugIDsMutex.RUnlock()
+
+	return stats
+}
+
+// String formats the stats for the command line
+func (s CacheStats) String() string {
+	var b strings.Builder
+	if disableCacheStore {
+		b.WriteString("Cache file: not stored (-nocache)\n")
+	} else {
+		fmt.Fprintf(&b, "Cache file: %s (%d KiB)\n", s.Path, s.SizeOnDisk/1024)
+	}
+	fmt.Fprintf(&b, "Projects: %d\nFiles: %d\nSlug IDs: %d\n", s.Mods, s.Files, s.SlugIDs)
+	if s.Mods > 0 {
+		fmt.Fprintf(&b, "Oldest project: %s\nNewest project: %s\n",
+			s.OldestQueried.Format(time.RFC1123), s.NewestQueried.Format(time.RFC1123))
+		for _, v := range s.Ages {
+			fmt.Fprintf(&b, "  %s: %d\n", v.Label, v.Count)
+		}
+	}
+	return b.String()
+}
+
+// parseCacheAge parses a duration such as 30d or 12h
+func parseCacheAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("Invalid age: %s", age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("Invalid age: %s", age)
+	}
+	return duration, nil
+}
+
+// purgeEditorCache removes projects and files last queried longer ago than maxAge.
+// Slug IDs never change, so they are kept.
+func purgeEditorCache(maxAge time.Duration) (mods, files int) {
+	cutoff := time.Now().Add(-maxAge)
+
+	mainCache.cachedModsMutex.Lock()
+	for k, v := range mainCache.CachedMods {
+		if v.LastQueried.Before(cutoff) {
+			delete(mainCache.CachedMods, k)
+			mods++
+		}
+	}
+	mainCache.cachedModsMutex.Unlock()
+
+	// Files cached before query times were recorded count as old
+	mainCache.cachedFilesMutex.Lock()
+	for k, v := range mainCache.CachedFiles {
+		if v.LastQueried.Before(cutoff) {
+			delete(mainCache.CachedFiles, k)
+			files++
+		}
+	}
+	mainCache.cachedFilesMutex.Unlock()
+
+	removeUnusedLastUsed()
+	if mods > 0 || files > 0 {
+		markCacheChanged()
+	}
+	return
+}
+
+// refreshProject fetches a project again, and one of its files if fileID isn't 0
+func refreshProject(projectID, fileID int) error {
+	_, err := fetchAddonData(projectID)
+	if err != nil {
+		return err
+	}
+	if fileID != 0 {
+		_, err = fetchFileData(projectID, fileID)
+	}
+	return err
+}
+
+// refreshModpackCache fetches every mod in a loaded modpack again
+func (m *Modpack) refreshModpackCache() (refreshed, failed int) {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for projectID, v := range m.Mods {
+		if projectID == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(projectID, fileID int) {
+			defer wg.Done()
+			err := refreshProject(projectID, fileID)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				failed++
+			} else {
+				refreshed++
+			}
+		}(projectID, v.FileID)
+	}
+	wg.Wait()
+	return
+}
+
+func getCacheStats(w io.Writer) {
+	json.NewEncoder(w).Encode(getEditorCacheStats())
+}
+
+func purgeCache(w http.ResponseWriter, maxAge string) {
+	age, err := parseCacheAge(maxAge)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	mods, files := purgeEditorCache(age)
+
+	json.NewEncoder(w).Encode(struct {
+		Mods  int
+		Files int
+	}{mods, files})
+}
+
+// refreshCache fetches one project, or every mod in the modpack if projectID is 0, and reloads the mod list
+func refreshCache(w http.ResponseWriter, pack *openModpack, projectID int) {
+	pack.mutex.RLock()
+	refreshPack := Modpack{
+		Folder:            pack.modpack.Folder,
+		CurseManifest:     pack.modpack.CurseManifest,
+		ServerSetupConfig: pack.modpack.ServerSetupConfig,
+		Mods:              make(map[int]ModInfo, len(pack.modpack.Mods)),
+	}
+	for k, v := range pack.modpack.Mods {
+		refreshPack.Mods[k] = v
+	}
+	loading := pack.modpack.LoadingMods
+	revision := pack.modpack.Revision
+	pack.mutex.RUnlock()
+	if loading {
+		writeError(w, errors.New("Mod list is still loading, try again when it has loaded"))
+		return
+	}
+
+	var refreshed, failed int
+	if projectID != 0 {
+		err := refreshProject(projectID, refreshPack.Mods[projectID].FileID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		refreshed = 1
+	} else {
+		refreshed, failed = refreshPack.refreshModpackCache()
+	}
+
+	// Update mod list
+	refreshPack.getModInfoList(nil)
+	pack.mutex.Lock()
+	if pack.modpack.Revision == revision && !pack.modpack.LoadingMods {
+		pack.modpack.Mods = refreshPack.Mods
+		pack.modpack.Diagnostics = refreshPack.Diagnostics
+	}
+	pack.mutex.Unlock()
+
+	json.NewEncoder(w).Encode(struct {
+		Refreshed   int
+		Failed      int
+		Mods        map[int]ModInfo
+		Diagnostics []Diagnostic
+	}{refreshed, failed, refreshPack.Mods, refreshPack.Diagnostics})
+}
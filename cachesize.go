@@ -0,0 +1,161 @@
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxCacheSize is the largest the cache file can grow to in bytes, or 0 for no limit
+var maxCacheSize int64
+
+// trimCachedMods stores only the project fields the editor uses, so the cache is smaller and faster to load
+var trimCachedMods bool
+
+// maxEvictionWrites limits how many times the cache is written again to make it fit
+const maxEvictionWrites = 5
+
+func markModUsed(addonID int) {
+	mainCache.lastUsedMutex.Lock()
+	mainCache.ModLastUsed[addonID] = time.Now()
+	mainCache.lastUsedMutex.Unlock()
+}
+
+func markFileUsed(fileID int) {
+	mainCache.lastUsedMutex.Lock()
+	mainCache.FileLastUsed[fileID] = time.Now()
+	mainCache.lastUsedMutex.Unlock()
+}
+
+// removeUnusedLastUsed removes use times of entries that are no longer cached
+func removeUnusedLastUsed() {
+	mainCache.cachedModsMutex.RLock()
+	mainCache.lastUsedMutex.Lock()
+	for k := range mainCache.ModLastUsed {
+		if _, ok := mainCache.CachedMods[k]; !ok {
+			delete(mainCache.ModLastUsed, k)
+		}
+	}
+	mainCache.lastUsedMutex.Unlock()
+	mainCache.cachedModsMutex.RUnlock()
+
+	mainCache.cachedFilesMutex.RLock()
+	mainCache.lastUsedMutex.Lock()
+	for k := range mainCache.FileLastUsed {
+		if _, ok := mainCache.CachedFiles[k]; !ok {
+			delete(mainCache.FileLastUsed, k)
+		}
+	}
+	mainCache.lastUsedMutex.Unlock()
+	mainCache.cachedFilesMutex.RUnlock()
+}
+
+// trimmed returns a copy of the project with only the fields the editor uses
+func (data AddonData) trimmed() AddonData {
+	trimmedData := AddonData{
+		ID:                     data.ID,
+		Name:                   data.Name,
+		Authors:                data.Authors,
+		WebsiteURL:             data.WebsiteURL,
+		Summary:                data.Summary,
+		DefaultFileID:          data.DefaultFileID,
+		LatestFiles:            data.LatestFiles,
+		Categories:             data.Categories,
+		PrimaryAuthorName:      data.PrimaryAuthorName,
+		Slug:                   data.Slug,
+		GameVersionLatestFiles: data.GameVersionLatestFiles,
+		Available:              data.Available,
+		LastQueried:            data.LastQueried,
+	}
+	// Only the default attachment is used, for the icon
+	for _, v := range data.Attachments {
+		if v.Default {
+			trimmedData.Attachments = append(trimmedData.Attachments, v)
+		}
+	}
+	return trimmedData
+}
+
+// trimEditorCache trims every cached project
+func trimEditorCache() {
+	mainCache.cachedModsMutex.Lock()
+	for k, v := range mainCache.CachedMods {
+		mainCache.CachedMods[k] = v.trimmed()
+	}
+	mainCache.cachedModsMutex.Unlock()
+	markCacheChanged()
+}
+
+// cacheEntryUse is when a cached project or file was last used
+type cacheEntryUse struct {
+	isFile   bool
+	id       int
+	lastUsed time.Time
+}
+
+// evictToFit removes the least recently used entries if the cache file is bigger than maxCacheSize.
+// It returns true if anything was removed, so the cache needs to be written again.
+func evictToFit() bool {
+	if maxCacheSize <= 0 || disableCacheStore {
+		return false
+	}
+	info, err := os.Stat(editorCachePath)
+	if err != nil || info.Size() <= maxCacheSize {
+		return false
+	}
+
+	// Entries could be different sizes, so aim a bit under the limit
+	fraction := 1 - float64(maxCacheSize)*0.9/float64(info.Size())
+	evicted := evictLeastRecentlyUsed(fraction)
+	if evicted > 0 {
+		log.Printf("Cache is bigger than the maximum size, removed %d least recently used entries", evicted)
+	}
+	return evicted > 0
+}
+
+// evictLeastRecentlyUsed removes a fraction of the cached projects and files, least recently used first.
+// Entries that were never used count as used when they were fetched.
+func evictLeastRecentlyUsed(fraction float64) int {
+	mainCache.cachedModsMutex.Lock()
+	defer mainCache.cachedModsMutex.Unlock()
+	mainCache.cachedFilesMutex.Lock()
+	defer mainCache.cachedFilesMutex.Unlock()
+	mainCache.lastUsedMutex.Lock()
+	defer mainCache.lastUsedMutex.Unlock()
+
+	entries := make([]cacheEntryUse, 0, len(mainCache.CachedMods)+len(mainCache.CachedFiles))
+	for k, v := range mainCache.CachedMods {
+		lastUsed, ok := mainCache.ModLastUsed[k]
+		if !ok {
+			lastUsed = v.LastQueried
+		}
+		entries = append(entries, cacheEntryUse{false, k, lastUsed})
+	}
+	for k, v := range mainCache.CachedFiles {
+		lastUsed, ok := mainCache.FileLastUsed[k]
+		if !ok {
+			lastUsed = v.LastQueried
+		}
+		entries = append(entries, cacheEntryUse{true, k, lastUsed})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	count := int(math.Ceil(float64(len(entries)) * fraction))
+	if count > len(entries) {
+		count = len(entries)
+	}
+	for _, v := range entries[:count] {
+		if v.isFile {
+			delete(mainCache.CachedFiles, v.id)
+			delete(mainCache.FileLastUsed, v.id)
+		} else {
+			delete(mainCache.CachedMods, v.id)
+			delete(mainCache.ModLastUsed, v.id)
+		}
+	}
+	return count
+}
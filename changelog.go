@@ -0,0 +1,337 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Changelog lists the differences between two versions of a modpack manifest
+type Changelog struct {
+	OldName             string
+	NewName             string
+	OldVersion          string
+	NewVersion          string
+	OldMinecraftVersion string
+	NewMinecraftVersion string
+	OldModLoaders       string
+	NewModLoaders       string
+	Added               []ChangelogMod
+	Removed             []ChangelogMod
+	Updated             []ChangelogMod
+}
+
+// ChangelogMod is a mod listed in a Changelog
+type ChangelogMod struct {
+	ProjectID   int
+	Name        string
+	WebsiteURL  string
+	OldFileName string
+	NewFileName string
+}
+
+// loadManifestFrom reads a manifest from a changelog source, which is either a pack folder,
+// a zip file, or "git:<revision>" for a revision of the manifest in packFolder's git repository
+func loadManifestFrom(source, packFolder string) (CurseManifest, error) {
+	var manifest CurseManifest
+	var data []byte
+
+	if strings.HasPrefix(source, "git:") {
+		if len(packFolder) == 0 {
+			return manifest, errors.New("A pack folder is needed to read git revisions")
+		}
+		revision, err := resolveGitRevision(packFolder, strings.TrimPrefix(source, "git:"))
+		if err != nil {
+			return manifest, err
+		}
+		out, err := runGit(packFolder, "show", revision+":./manifest.json")
+		if err != nil {
+			return manifest, err
+		}
+		data = []byte(out)
+	} else {
+		stat, err := os.Stat(source)
+		if err != nil {
+			return manifest, err
+		}
+		if stat.IsDir() {
+			data, err = ioutil.ReadFile(filepath.Join(source, "manifest.json"))
+		} else {
+			data, err = readManifestZip(source)
+		}
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	err := json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// resolveGitRevision returns the commit hash of a revision. Revisions come from requests,
+// so ones starting with - are rejected, as git would read them as options.
+func resolveGitRevision(packFolder, revision string) (string, error) {
+	if len(revision) == 0 || strings.HasPrefix(revision, "-") {
+		return "", fmt.Errorf("Invalid git revision %q", revision)
+	}
+	out, err := runGit(packFolder, "rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("Unknown git revision %q", revision)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// readManifestZip reads the manifest.json closest to the root of a zip file
+func readManifestZip(zipPath string) ([]byte, error) {
+	data, ok, err := readZipFile(zipPath, "manifest.json")
+	if err == nil && !ok {
+		err = fmt.Errorf("No manifest.json found in %s", zipPath)
+	}
+	return data, err
+}
+
+// readZipFile reads the file with the given name closest to the root of a zip file.
+// ok is false if there is no file with that name.
+func readZipFile(zipPath, name string) ([]byte, bool, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer zr.Close()
+
+	var found *zip.File
+	for _, v := range zr.File {
+		if path.Base(v.Name) != name {
+			continue
+		}
+		if found == nil || strings.Count(v.Name, "/") < strings.Count(found.Name, "/") {
+			found = v
+		}
+	}
+	if found == nil {
+		return nil, false, nil
+	}
+
+	file, err := found.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	return data, err == nil, err
+}
+
+func modLoadersString(manifest CurseManifest) string {
+	var ids []string
+	for _, v := range manifest.Minecraft.ModLoaders {
+		// Put the primary modloader first
+		if v.Primary {
+			ids = append([]string{v.ID}, ids...)
+		} else {
+			ids = append(ids, v.ID)
+		}
+	}
+	return strings.Join(ids, ", ")
+}
+
+func makeChangelog(oldManifest, newManifest CurseManifest) Changelog {
+	changelog := Changelog{
+		OldName:             oldManifest.Name,
+		NewName:             newManifest.Name,
+		OldVersion:          oldManifest.Version,
+		NewVersion:          newManifest.Version,
+		OldMinecraftVersion: oldManifest.Minecraft.Version,
+		NewMinecraftVersion: newManifest.Minecraft.Version,
+		OldModLoaders:       modLoadersString(oldManifest),
+		NewModLoaders:       modLoadersString(newManifest),
+	}
+
+	oldFiles := make(map[int]int)
+	for _, v := range oldManifest.Files {
+		oldFiles[v.ProjectID] = v.FileID
+	}
+	newFiles := make(map[int]int)
+	for _, v := range newManifest.Files {
+		newFiles[v.ProjectID] = v.FileID
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	resolve := func(list *[]ChangelogMod, projectID, oldFileID, newFileID int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mod := ChangelogMod{ProjectID: projectID, Name: fmt.Sprintf("Project %d", projectID)}
+			if data, err := requestAddonData(projectID); err == nil {
+				mod.Name = data.Name
+				mod.WebsiteURL = data.WebsiteURL
+			}
+			if oldFileID > 0 {
+				mod.OldFileName = changelogFileName(projectID, oldFileID)
+			}
+			if newFileID > 0 {
+				mod.NewFileName = changelogFileName(projectID, newFileID)
+			}
+			mutex.Lock()
+			*list = append(*list, mod)
+			mutex.Unlock()
+		}()
+	}
+
+	for projectID, newFileID := range newFiles {
+		oldFileID, ok := oldFiles[projectID]
+		if !ok {
+			resolve(&changelog.Added, projectID, 0, newFileID)
+		} else if oldFileID != newFileID {
+			resolve(&changelog.Updated, projectID, oldFileID, newFileID)
+		}
+	}
+	for projectID, oldFileID := range oldFiles {
+		if _, ok := newFiles[projectID]; !ok {
+			resolve(&changelog.Removed, projectID, oldFileID, 0)
+		}
+	}
+	wg.Wait()
+
+	for _, list := range [][]ChangelogMod{changelog.Added, changelog.Removed, changelog.Updated} {
+		sort.Slice(list, func(i, j int) bool {
+			return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+		})
+	}
+	return changelog
+}
+
+func changelogFileName(projectID, fileID int) string {
+	data, err := requestFileData(projectID, fileID)
+	if err != nil || len(data.FileName) == 0 {
+		return fmt.Sprintf("file %d", fileID)
+	}
+	return data.FileName
+}
+
+// Markdown formats the changelog as a Markdown document
+func (c Changelog) Markdown() string {
+	var out strings.Builder
+
+	title := c.NewName
+	if len(title) == 0 {
+		title = "Changelog"
+	}
+	if len(c.NewVersion) > 0 {
+		if len(c.OldVersion) > 0 && c.OldVersion != c.NewVersion {
+			title += fmt.Sprintf(" %s → %s", c.OldVersion, c.NewVersion)
+		} else {
+			title += " " + c.NewVersion
+		}
+	}
+	fmt.Fprintf(&out, "# %s\n\n", title)
+
+	if c.OldMinecraftVersion != c.NewMinecraftVersion {
+		fmt.Fprintf(&out, "- Minecraft version: %s → %s\n", orNone(c.OldMinecraftVersion), orNone(c.NewMinecraftVersion))
+	}
+	if c.OldModLoaders != c.NewModLoaders {
+		fmt.Fprintf(&out, "- Modloader: %s → %s\n", orNone(c.OldModLoaders), orNone(c.NewModLoaders))
+	}
+	if c.OldMinecraftVersion != c.NewMinecraftVersion || c.OldModLoaders != c.NewModLoaders {
+		out.WriteString("\n")
+	}
+
+	modName := func(mod ChangelogMod) string {
+		if len(mod.WebsiteURL) > 0 {
+			return fmt.Sprintf("[%s](%s)", mod.Name, mod.WebsiteURL)
+		}
+		return mod.Name
+	}
+
+	if len(c.Added) > 0 {
+		out.WriteString("## Added\n\n")
+		for _, v := range c.Added {
+			fmt.Fprintf(&out, "- %s (%s)\n", modName(v), v.NewFileName)
+		}
+		out.WriteString("\n")
+	}
+	if len(c.Removed) > 0 {
+		out.WriteString("## Removed\n\n")
+		for _, v := range c.Removed {
+			fmt.Fprintf(&out, "- %s\n", modName(v))
+		}
+		out.WriteString("\n")
+	}
+	if len(c.Updated) > 0 {
+		out.WriteString("## Updated\n\n")
+		for _, v := range c.Updated {
+			fmt.Fprintf(&out, "- %s: %s → %s\n", modName(v), v.OldFileName, v.NewFileName)
+		}
+		out.WriteString("\n")
+	}
+
+	if len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0 {
+		out.WriteString("No mods changed.\n")
+	}
+	return out.String()
+}
+
+func orNone(value string) string {
+	if len(value) == 0 {
+		return "(none)"
+	}
+	return value
+}
+
+// getChangelog compares two changelog sources. As well as the sources accepted by loadManifestFrom,
+// "disk" is the loaded modpack's saved manifest and "current" is the edited modpack sent by the client
+func getChangelog(w http.ResponseWriter, pack *openModpack, from, to string, current Modpack) {
+	packFolder := pack.folder
+
+	if len(from) == 0 {
+		from = "disk"
+	}
+	if len(to) == 0 {
+		to = "current"
+	}
+
+	manifests := make([]CurseManifest, 2)
+	for i, source := range []string{from, to} {
+		var err error
+		switch source {
+		case "disk":
+			manifests[i], err = loadManifestFrom(packFolder, packFolder)
+		case "current":
+			if current.Mods == nil {
+				writeError(w, errors.New("No modpack sent to compare"))
+				return
+			}
+			current.Folder = packFolder
+			err = current.updateModLists()
+			manifests[i] = current.CurseManifest
+		default:
+			if !strings.HasPrefix(source, "git:") {
+				source, err = filepath.Abs(source)
+				if err != nil {
+					break
+				}
+			}
+			manifests[i], err = loadManifestFrom(source, packFolder)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	changelog := makeChangelog(manifests[0], manifests[1])
+
+	json.NewEncoder(w).Encode(struct {
+		Changelog Changelog
+		Markdown  string
+	}{changelog, changelog.Markdown()})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// CloneOptions are the fields changed in a cloned modpack. Only the fields named in Reset
+// are changed, so they can be set to an empty value.
+type CloneOptions struct {
+	// Reset contains name, version, projectID and/or modpackUrl
+	Reset      []string
+	Name       string
+	Version    string
+	ProjectID  int
+	ModpackURL string
+}
+
+// apply changes the fields named in Reset
+func (o CloneOptions) apply(m *Modpack) error {
+	for _, v := range o.Reset {
+		switch v {
+		case "name":
+			m.CurseManifest.Name = o.Name
+			m.ServerSetupConfig.Modpack.Name = o.Name
+		case "version":
+			m.CurseManifest.Version = o.Version
+		case "projectID":
+			m.CurseManifest.ProjectID = o.ProjectID
+		case "modpackUrl":
+			m.ServerSetupConfig.Install.ModpackURL = o.ModpackURL
+		default:
+			return fmt.Errorf("%s can't be reset when cloning", v)
+		}
+	}
+	return nil
+}
+
+// cloneModpack copies the saved config files, settings and overrides of a modpack into a new folder,
+// changes the given fields and opens the clone
+func cloneModpack(w http.ResponseWriter, pack *openModpack, folder string, options CloneOptions) {
+	folderAbsolute, err := filepath.Abs(folder)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := os.Stat(folderAbsolute); err == nil {
+		writeError(w, errors.New("The folder to clone into already exists"))
+		return
+	}
+
+	pack.mutex.RLock()
+	newPack := Modpack{
+		Folder:            folderAbsolute,
+		CurseManifest:     pack.modpack.CurseManifest,
+		ServerSetupConfig: pack.modpack.ServerSetupConfig,
+		Settings:          pack.modpack.Settings,
+	}
+	pack.mutex.RUnlock()
+	err = options.apply(&newPack)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = os.MkdirAll(folderAbsolute, os.ModePerm)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = newPack.saveConfigFiles()
+	if err == nil && newPack.Settings != (PackSettings{}) {
+		err = newPack.savePackSettings()
+	}
+	if err == nil && len(newPack.CurseManifest.Overrides) > 0 {
+		err = copyFolder(filepath.Join(pack.folder, newPack.CurseManifest.Overrides), filepath.Join(folderAbsolute, newPack.CurseManifest.Overrides))
+	}
+	if err != nil {
+		// The folder didn't exist before, so don't leave half a modpack behind
+		os.RemoveAll(folderAbsolute)
+		writeError(w, err)
+		return
+	}
+
+	loadModpackFolder(w, folderAbsolute)
+}
+
+// copyFolder copies a folder and everything in it. Nothing is copied if src doesn't exist.
+func copyFolder(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, relPath), os.ModePerm)
+		}
+		return copyFile(path, filepath.Join(dst, relPath), info.Mode())
+	})
+}
+
+// copyFile copies a file, creating it with the given mode
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
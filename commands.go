@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// runCommand runs a command line subcommand, and returns the exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "changelog":
+		return changelogCommand(args[1:])
+	case "cache":
+		return cacheCommand(args[1:])
+	case "lint":
+		return lintCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Available commands: changelog, cache, lint")
+		return 2
+	}
+}
+
+func changelogCommand(args []string) int {
+	flags := flag.NewFlagSet("changelog", flag.ContinueOnError)
+	packFolder := flags.String("pack", ".", "The modpack folder, used to read git revisions")
+	output := flags.String("o", "", "Write the changelog to this file instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: modpack-editor changelog [options] <old> <new>")
+		fmt.Fprintln(flags.Output(), "Each version can be a modpack folder, a modpack zip file or git:<revision>.")
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	oldManifest, err := loadManifestFrom(flags.Arg(0), *packFolder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	newManifest, err := loadManifestFrom(flags.Arg(1), *packFolder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", flags.Arg(1), err)
+		return 1
+	}
+
+	markdown := makeChangelog(oldManifest, newManifest).Markdown()
+	flushEditorCache()
+
+	if len(*output) > 0 {
+		err = ioutil.WriteFile(*output, []byte(markdown), 0664)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing changelog: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Print(markdown)
+	return 0
+}
+
+func cacheCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: modpack-editor cache <stats|refresh|purge|export|import> [options]")
+		return 2
+	}
+
+	var code int
+	switch args[0] {
+	case "stats":
+		fmt.Print(getEditorCacheStats())
+		return 0
+	case "refresh":
+		code = cacheRefreshCommand(args[1:])
+	case "purge":
+		code = cachePurgeCommand(args[1:])
+	case "export":
+		return cacheExportCommand(args[1:])
+	case "import":
+		code = cacheImportCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cache command: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Available cache commands: stats, refresh, purge, export, import")
+		return 2
+	}
+	flushEditorCache()
+	return code
+}
+
+func cacheRefreshCommand(args []string) int {
+	flags := flag.NewFlagSet("cache refresh", flag.ContinueOnError)
+	packFolder := flags.String("pack", "", "Refresh every mod in this modpack folder")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: modpack-editor cache refresh [options] [project ID...]")
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 && len(*packFolder) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, v := range flags.Args() {
+		projectID, err := strconv.Atoi(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid project ID: %s\n", v)
+			return 2
+		}
+		err = refreshProject(projectID, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error refreshing %d: %v\n", projectID, err)
+			code = 1
+		}
+	}
+
+	if len(*packFolder) > 0 {
+		pack := Modpack{Folder: *packFolder}
+		err = pack.loadConfigFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading modpack: %v\n", err)
+			return 1
+		}
+		pack.getModInfoList(nil)
+		refreshed, failed := pack.refreshModpackCache()
+		fmt.Printf("Refreshed %d mods, %d failed\n", refreshed, failed)
+		if failed > 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+func cachePurgeCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: modpack-editor cache purge <age>")
+		fmt.Fprintln(os.Stderr, "Removes entries last fetched longer ago than the age, such as 30d or 12h.")
+		return 2
+	}
+	age, err := parseCacheAge(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	mods, files := purgeEditorCache(age)
+	fmt.Printf("Removed %d projects and %d files\n", mods, files)
+	return 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severities of diagnostics
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Codes of diagnostics found while loading mods
+const (
+	DiagnosticModRequestFailed  = "modRequestFailed"
+	DiagnosticFileRequestFailed = "fileRequestFailed"
+	DiagnosticInvalidFileURL    = "invalidFileURL"
+	DiagnosticSlugLookupFailed  = "slugLookupFailed"
+	DiagnosticIncompatibleFile  = "incompatibleFile"
+)
+
+// Diagnostic is a problem found with a modpack or one of its mods
+type Diagnostic struct {
+	Code     string
+	Severity string
+	Message  string
+	// ProjectID and URL are set if the problem is with a mod, or a file in AdditionalFiles
+	ProjectID int    `json:",omitempty"`
+	URL       string `json:",omitempty"`
+}
+
+// String formats the diagnostic for the command line
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s [%s]: %s", d.Severity, d.Code, d.Message)
+	if d.ProjectID != 0 {
+		s += fmt.Sprintf(" (project %d)", d.ProjectID)
+	} else if len(d.URL) > 0 {
+		s += fmt.Sprintf(" (%s)", d.URL)
+	}
+	return s
+}
+
+// sortDiagnostics sorts diagnostics by project ID, then URL, then code, so they stay in the same order
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.Slice(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.ProjectID != b.ProjectID {
+			return a.ProjectID < b.ProjectID
+		}
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.Code < b.Code
+	})
+}
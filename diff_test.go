@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns lines 1 to n, with the given lines replaced
+func numberedLines(n int, replaced map[int]string) string {
+	var out strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replaced[i]; ok {
+			out.WriteString(line + "\n")
+		} else {
+			fmt.Fprintf(&out, "%d\n", i)
+		}
+	}
+	return out.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"added to empty", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"all removed", "a\nb\n", "", "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"changed in the middle", numberedLines(10, nil), numberedLines(10, map[int]string{5: "five"}),
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"},
+		{"added at the end", "a\nb\n", "a\nb\nc\n", "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n b\n+c\n"},
+		{"inserted at the start", "b\nc\n", "a\nb\nc\n", "--- old\n+++ new\n@@ -1,2 +1,3 @@\n+a\n b\n c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("old", "new", tt.oldText, tt.newText)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		replaced map[int]string
+		want     []string
+	}{
+		{"one change", map[int]string{10: "x"}, []string{"@@ -7,7 +7,7 @@"}},
+		{"close changes share a hunk", map[int]string{3: "x", 9: "y"}, []string{"@@ -1,12 +1,12 @@"}},
+		{"far changes have their own hunks", map[int]string{2: "x", 15: "y"}, []string{"@@ -1,5 +1,5 @@", "@@ -12,7 +12,7 @@"}},
+		{"change on the last line", map[int]string{20: "x"}, []string{"@@ -17,4 +17,4 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := unifiedDiff("old", "new", numberedLines(20, nil), numberedLines(20, tt.replaced))
+			var hunks []string
+			for _, line := range strings.Split(diff, "\n") {
+				if strings.HasPrefix(line, "@@") {
+					hunks = append(hunks, line)
+				}
+			}
+			if strings.Join(hunks, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got hunks %q, want %q", hunks, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{"", "", nil},
+		{"a b c", "a b c", []string{" a", " b", " c"}},
+		{"a b c", "a c", []string{" a", "-b", " c"}},
+		{"a c", "a b c", []string{" a", "+b", " c"}},
+		{"a b c d", "a x c y", []string{" a", "-b", "+x", " c", "-d", "+y"}},
+		{"a b", "c d", []string{"-a", "-b", "+c", "+d"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, op := range diffLines(strings.Fields(tt.a), strings.Fields(tt.b)) {
+			got = append(got, string(op.kind)+op.line)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Event is pushed to connected browsers through the /events stream
+type Event struct {
+	// Type is the name of the Server-Sent Event
+	Type string
+	// ID is the ID of the modpack the event is about
+	ID   string
+	Data interface{}
+}
+
+// eventHub sends events to every subscribed browser
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]string
+	// closed is closed on shutdown, to end every stream
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var events = eventHub{
+	subscribers: make(map[chan Event]string),
+	closed:      make(chan struct{}),
+}
+
+// close ends every event stream, so the server can shut down
+func (h *eventHub) close() {
+	h.closeOnce.Do(func() {
+		close(h.closed)
+	})
+}
+
+// subscribe returns a channel receiving events for a modpack ID, or all events if the ID is empty
+func (h *eventHub) subscribe(id string) chan Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	// Buffered, so a slow browser doesn't block everything else
+	ch := make(chan Event, 64)
+	h.subscribers[ch] = id
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.subscribers, ch)
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for ch, id := range h.subscribers {
+		if len(id) > 0 && id != e.ID {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			// Drop events for browsers that aren't keeping up
+		}
+	}
+}
+
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(500)
+		return
+	}
+
+	// Subscribe before the browser sees the stream open, so it can catch up without missing events
+	ch := events.subscribe(r.URL.Query().Get("ID"))
+	defer events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-ch:
+			data, err := json.Marshal(struct {
+				ID   string
+				Data interface{}
+			}{e.ID, e.Data})
+			if err != nil {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-events.closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitCommitFiles are the files staged and committed when GitCommit is enabled
+var gitCommitFiles = []string{"manifest.json", "server-setup-config.yaml"}
+
+func runGit(folder string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = folder
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s failed: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// checkGitStaging returns an error if the pack folder isn't in a git repository,
+// or if anything other than the config files is staged
+func checkGitStaging(folder string) error {
+	prefix, err := runGit(folder, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	prefix = strings.TrimSpace(prefix)
+
+	staged, err := runGit(folder, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return err
+	}
+
+	var unrelated []string
+	for _, path := range strings.Split(staged, "\x00") {
+		if len(path) == 0 {
+			continue
+		}
+		related := false
+		for _, v := range gitCommitFiles {
+			if path == prefix+v {
+				related = true
+				break
+			}
+		}
+		if !related {
+			unrelated = append(unrelated, path)
+		}
+	}
+	if len(unrelated) > 0 {
+		return fmt.Errorf("Refusing to commit, unrelated changes are staged: %s", strings.Join(unrelated, ", "))
+	}
+	return nil
+}
+
+// commitConfigFiles stages the config files and commits them, if they changed
+func commitConfigFiles(folder string, changes PackChanges) error {
+	_, err := runGit(folder, append([]string{"add", "--"}, gitCommitFiles...)...)
+	if err != nil {
+		return err
+	}
+
+	// Exit code 0 means nothing is staged
+	_, err = runGit(folder, append([]string{"diff", "--cached", "--quiet", "--"}, gitCommitFiles...)...)
+	if err == nil {
+		return nil
+	}
+
+	_, err = runGit(folder, "commit", "-m", gitCommitMessage(changes))
+	return err
+}
+
+// gitCommitMessage makes a commit message with a short subject and the full list of changes
+func gitCommitMessage(changes PackChanges) string {
+	var subject []string
+	modNames := func(verb string, list []ModChange) {
+		switch {
+		case len(list) == 0:
+			return
+		case len(list) <= 2:
+			names := make([]string, len(list))
+			for i, v := range list {
+				names[i] = v.Name
+			}
+			subject = append(subject, verb+" "+strings.Join(names, " and "))
+		default:
+			subject = append(subject, fmt.Sprintf("%s %d mods", verb, len(list)))
+		}
+	}
+	modNames("Add", changes.Added)
+	modNames("Remove", changes.Removed)
+	modNames("Update", changes.Updated)
+	if len(changes.SidesChanged) > 0 {
+		subject = append(subject, "Change mod sides")
+	}
+	if len(changes.RequiredChanged) > 0 {
+		subject = append(subject, "Change optional mods")
+	}
+	if len(changes.Settings) > 0 {
+		subject = append(subject, "Change settings")
+	}
+	if len(subject) == 0 {
+		subject = append(subject, "Update modpack")
+	}
+	// Only the first word should be capitalised
+	for i := 1; i < len(subject); i++ {
+		subject[i] = strings.ToLower(subject[i][:1]) + subject[i][1:]
+	}
+
+	var body strings.Builder
+	body.WriteString(strings.Join(subject, ", "))
+	body.WriteString("\n")
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&body, "\n%s:\n", title)
+		for _, v := range lines {
+			fmt.Fprintf(&body, "- %s\n", v)
+		}
+	}
+	var lines []string
+	for _, v := range changes.Added {
+		lines = append(lines, fmt.Sprintf("%s (%s)", v.Name, sideName(*v.New)))
+	}
+	section("Added", lines)
+	lines = nil
+	for _, v := range changes.Removed {
+		lines = append(lines, v.Name)
+	}
+	section("Removed", lines)
+	lines = nil
+	for _, v := range changes.Updated {
+		lines = append(lines, fmt.Sprintf("%s (file %d -> %d)", v.Name, v.Old.FileID, v.New.FileID))
+	}
+	section("Updated", lines)
+	lines = nil
+	for _, v := range changes.SidesChanged {
+		lines = append(lines, fmt.Sprintf("%s (%s -> %s)", v.Name, sideName(*v.Old), sideName(*v.New)))
+	}
+	section("Sides changed", lines)
+	lines = nil
+	for _, v := range changes.RequiredChanged {
+		lines = append(lines, fmt.Sprintf("%s (%s -> %s)", v.Name, requiredName(*v.Old), requiredName(*v.New)))
+	}
+	section("Required changed", lines)
+	lines = nil
+	for _, v := range changes.Settings {
+		lines = append(lines, fmt.Sprintf("%s: %q -> %q", v.Setting, v.Old, v.New))
+	}
+	section("Settings changed", lines)
+
+	return body.String()
+}
+
+func sideName(side ModSide) string {
+	switch {
+	case side.OnClient && side.OnServer:
+		return "client and server"
+	case side.OnClient:
+		return "client only"
+	case side.OnServer:
+		return "server only"
+	}
+	return "no sides"
+}
+
+func requiredName(side ModSide) string {
+	if side.Optional {
+		return "optional"
+	}
+	return "required"
+}
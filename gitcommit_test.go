@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGitCommitMessageSubject(t *testing.T) {
+	both := ModSide{FileID: 1, OnClient: true, OnServer: true}
+	named := func(name string) ModSide {
+		side := both
+		side.Name = name
+		return side
+	}
+	tests := []struct {
+		name     string
+		oldSides map[int]ModSide
+		newSides map[int]ModSide
+		settings []SettingChange
+		want     string
+	}{
+		{"nothing", nil, nil, nil, "Update modpack"},
+		{"one added", nil, map[int]ModSide{1: named("JEI")}, nil, "Add JEI"},
+		{"two added", nil, map[int]ModSide{1: named("JEI"), 2: named("Botania")}, nil, "Add Botania and JEI"},
+		{"many added", nil, map[int]ModSide{1: named("a"), 2: named("b"), 3: named("c")}, nil, "Add 3 mods"},
+		{"added and removed", map[int]ModSide{1: named("JEI")}, map[int]ModSide{2: named("Botania")}, nil, "Add Botania, remove JEI"},
+		{"updated", map[int]ModSide{1: named("JEI")}, map[int]ModSide{1: {Name: "JEI", FileID: 2, OnClient: true, OnServer: true}}, nil, "Update JEI"},
+		{"sides", map[int]ModSide{1: named("JEI")}, map[int]ModSide{1: {Name: "JEI", FileID: 1, OnClient: true}}, nil, "Change mod sides"},
+		{"optional", map[int]ModSide{1: named("JEI")}, map[int]ModSide{1: {Name: "JEI", FileID: 1, OnClient: true, OnServer: true, Optional: true}}, nil, "Change optional mods"},
+		{"settings", nil, nil, []SettingChange{{"manifest.version", "1.0", "1.1"}}, "Change settings"},
+		{"update and settings", map[int]ModSide{1: named("JEI")}, map[int]ModSide{1: {Name: "JEI", FileID: 2, OnClient: true, OnServer: true}}, []SettingChange{{"manifest.version", "1.0", "1.1"}}, "Update JEI, change settings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := compareModSides(tt.oldSides, tt.newSides)
+			changes.Settings = tt.settings
+			subject := strings.SplitN(gitCommitMessage(changes), "\n", 2)[0]
+			if subject != tt.want {
+				t.Errorf("got subject %q, want %q", subject, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitCommitMessageBody(t *testing.T) {
+	changes := compareModSides(map[int]ModSide{
+		1: {Name: "JEI", FileID: 1, OnClient: true, OnServer: true},
+		2: {Name: "Optifine", FileID: 5, OnClient: true, OnServer: true},
+		3: {Name: "Old mod", FileID: 7, OnClient: true, OnServer: true},
+	}, map[int]ModSide{
+		1: {Name: "JEI", FileID: 2, OnClient: true, OnServer: true},
+		2: {Name: "Optifine", FileID: 5, OnClient: true, Optional: true},
+		4: {Name: "Dynmap", FileID: 9, OnServer: true},
+	})
+	changes.Settings = []SettingChange{{"serverConfig.launch.maxRam", "5G", "6G"}}
+
+	want := `Add Dynmap, remove Old mod, update JEI, change mod sides, change optional mods, change settings
+
+Added:
+- Dynmap (server only)
+
+Removed:
+- Old mod
+
+Updated:
+- JEI (file 1 -> 2)
+
+Sides changed:
+- Optifine (client and server -> client only)
+
+Required changed:
+- Optifine (required -> optional)
+
+Settings changed:
+- serverConfig.launch.maxRam: "5G" -> "6G"
+`
+	if got := gitCommitMessage(changes); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// editorDataFolder is the folder inside a modpack where modpack-editor keeps its own files
+const editorDataFolder = ".modpack-editor"
+
+// maxSnapshots is how many snapshots are kept for each modpack, older ones are deleted
+const maxSnapshots = 100
+
+// Snapshot is a copy of a modpack's config files and mod sides, recorded on each save
+type Snapshot struct {
+	ID       string
+	Time     time.Time
+	Revision string
+	Summary  string
+	Changes  PackChanges
+}
+
+// snapshotContents is the saved data of a Snapshot
+type snapshotContents struct {
+	Manifest []byte
+	Config   []byte
+	Sides    map[int]ModSide
+}
+
+func historyFolder(packFolder string) string {
+	return filepath.Join(packFolder, editorDataFolder, "history")
+}
+
+func hasSnapshots(packFolder string) bool {
+	files, err := ioutil.ReadDir(historyFolder(packFolder))
+	return err == nil && len(files) > 0
+}
+
+// recordSnapshot copies the config files currently on disk into the edit history, with the sides of their mods
+func recordSnapshot(packFolder string, sides map[int]ModSide, changes PackChanges, summary string) (Snapshot, error) {
+	snapshot := Snapshot{
+		Time:    time.Now(),
+		Summary: summary,
+		Changes: changes,
+	}
+
+	manifest, config, err := readConfigFiles(packFolder)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Revision = packRevision(manifest, config)
+	sidesData, err := json.Marshal(sides)
+	if err != nil {
+		return snapshot, err
+	}
+	meta, err := json.Marshal(snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// IDs are timestamps, so they sort in the order they were made
+	baseID := snapshot.Time.UTC().Format("20060102-150405.000")
+	snapshot.ID = baseID
+	folder := filepath.Join(historyFolder(packFolder), snapshot.ID)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(folder); os.IsNotExist(err) {
+			break
+		}
+		snapshot.ID = fmt.Sprintf("%s-%d", baseID, i)
+		folder = filepath.Join(historyFolder(packFolder), snapshot.ID)
+	}
+
+	err = os.MkdirAll(folder, os.ModePerm)
+	if err != nil {
+		return snapshot, err
+	}
+	err = ignoreHistory(packFolder)
+	if err != nil {
+		return snapshot, err
+	}
+	for fileName, data := range map[string][]byte{
+		"manifest.json":            manifest,
+		"server-setup-config.yaml": config,
+		"sides.json":               sidesData,
+		"snapshot.json":            meta,
+	} {
+		err = ioutil.WriteFile(filepath.Join(folder, fileName), data, 0664)
+		if err != nil {
+			return snapshot, err
+		}
+	}
+	return snapshot, pruneSnapshots(packFolder)
+}
+
+// currentSnapshot returns the newest snapshot, first recording the files on disk
+// if they were changed outside the editor since it was made
+func currentSnapshot(m *Modpack) (snapshotContents, error) {
+	var contents snapshotContents
+	snapshots, err := listSnapshots(m.Folder)
+	if err != nil {
+		return contents, err
+	}
+	if len(snapshots) == 0 {
+		return contents, errors.New("The modpack has no edit history")
+	}
+	newest, err := readSnapshot(m.Folder, snapshots[0].ID)
+	if err != nil {
+		return contents, err
+	}
+	manifest, config, err := readConfigFiles(m.Folder)
+	if err != nil {
+		return contents, err
+	}
+	revision := packRevision(manifest, config)
+	if snapshots[0].Revision == revision {
+		return newest, nil
+	}
+
+	oldCurseManifest, oldServerSetupConfig, err := parseConfigFiles(newest.Manifest, newest.Config)
+	if err != nil {
+		return contents, err
+	}
+	curseManifest, serverSetupConfig, err := parseConfigFiles(manifest, config)
+	if err != nil {
+		return contents, err
+	}
+	contents = snapshotContents{manifest, config, sidesFromConfigFiles(curseManifest, serverSetupConfig)}
+	// Use the loaded mods if they are for these files, they include server-only mods that aren't cached
+	if m.Revision == revision && !m.LoadingMods {
+		contents.Sides = m.modSides()
+	}
+	changes := compareModSides(sidesFromConfigFiles(oldCurseManifest, oldServerSetupConfig), sidesFromConfigFiles(curseManifest, serverSetupConfig))
+	changes.Settings = compareSettings(oldCurseManifest, oldServerSetupConfig, curseManifest, serverSetupConfig)
+	_, err = recordSnapshot(m.Folder, contents.Sides, changes, "Changed outside the editor: "+changes.String())
+	return contents, err
+}
+
+// ignoreHistory keeps the edit history out of git, so it isn't left untracked when saves are committed
+func ignoreHistory(packFolder string) error {
+	path := filepath.Join(packFolder, editorDataFolder, ".gitignore")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte("history/\n"), 0664)
+}
+
+// pruneSnapshots deletes the oldest snapshots, keeping maxSnapshots
+func pruneSnapshots(packFolder string) error {
+	files, err := ioutil.ReadDir(historyFolder(packFolder))
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, v := range files {
+		if v.IsDir() {
+			ids = append(ids, v.Name())
+		}
+	}
+	// ReadDir sorts by name, so the oldest snapshots are first
+	for len(ids) > maxSnapshots {
+		err = os.RemoveAll(filepath.Join(historyFolder(packFolder), ids[0]))
+		if err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// listSnapshots returns the edit history of a modpack, newest first
+func listSnapshots(packFolder string) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	files, err := ioutil.ReadDir(historyFolder(packFolder))
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, v := range files {
+		if !v.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(historyFolder(packFolder), v.Name(), "snapshot.json"))
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		err = json.Unmarshal(data, &snapshot)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.ID = v.Name()
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+func readSnapshot(packFolder, id string) (snapshotContents, error) {
+	var contents snapshotContents
+	// Don't allow IDs to escape the history folder
+	if len(id) == 0 || filepath.Base(id) != id || id == "." || id == ".." {
+		return contents, fmt.Errorf("Invalid snapshot ID: %s", id)
+	}
+
+	folder := filepath.Join(historyFolder(packFolder), id)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return contents, fmt.Errorf("Snapshot not found: %s", id)
+	}
+
+	manifest, config, err := readConfigFiles(folder)
+	if err != nil {
+		return contents, err
+	}
+	contents.Manifest = manifest
+	contents.Config = config
+
+	sides, err := ioutil.ReadFile(filepath.Join(folder, "sides.json"))
+	if err != nil {
+		return contents, err
+	}
+	err = json.Unmarshal(sides, &contents.Sides)
+	return contents, err
+}
+
+// compareSnapshots summarises the changes between two snapshots, and makes a unified diff of their files
+func compareSnapshots(from, to snapshotContents) (PackChanges, string, error) {
+	changes := compareModSides(from.Sides, to.Sides)
+
+	fromManifest, fromConfig, err := parseConfigFiles(from.Manifest, from.Config)
+	if err != nil {
+		return changes, "", err
+	}
+	toManifest, toConfig, err := parseConfigFiles(to.Manifest, to.Config)
+	if err != nil {
+		return changes, "", err
+	}
+	changes.Settings = compareSettings(fromManifest, fromConfig, toManifest, toConfig)
+
+	diff := unifiedDiff("a/manifest.json", "b/manifest.json", string(from.Manifest), string(to.Manifest)) +
+		unifiedDiff("a/server-setup-config.yaml", "b/server-setup-config.yaml", string(from.Config), string(to.Config))
+	return changes, diff, nil
+}
+
+func listHistory(w http.ResponseWriter, pack *openModpack) {
+	snapshots, err := listSnapshots(pack.folder)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Snapshots []Snapshot
+	}{snapshots})
+}
+
+func diffHistory(w http.ResponseWriter, pack *openModpack, fromID, toID string) {
+	from, err := readSnapshot(pack.folder, fromID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	to, err := readSnapshot(pack.folder, toID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	changes, diff, err := compareSnapshots(from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Summary string
+		Changes PackChanges
+		Diff    string
+	}{changes.String(), changes, diff})
+}
+
+// restoreHistory writes a snapshot's config files, if the files on disk are still at the given revision
+func restoreHistory(w http.ResponseWriter, pack *openModpack, id, revision string) {
+	saveLock.RLock()
+	defer saveLock.RUnlock()
+	pack.mutex.Lock()
+	defer pack.mutex.Unlock()
+
+	// Don't overwrite changes made by someone else, as saving doesn't
+	if len(revision) == 0 {
+		writeError(w, errors.New("No revision given, reload the modpack before restoring"))
+		return
+	}
+	conflict, err := checkRevision(&pack.modpack, revision)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if conflict != nil {
+		writeConflict(w, conflict)
+		return
+	}
+
+	snapshot, err := readSnapshot(pack.folder, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// The files are overwritten, so they must be in the history for the restore to be undone
+	current, err := currentSnapshot(&pack.modpack)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(pack.folder, "manifest.json"), snapshot.Manifest, 0664)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = ioutil.WriteFile(filepath.Join(pack.folder, "server-setup-config.yaml"), snapshot.Config, 0664)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	restoredPack := Modpack{Folder: pack.folder}
+	err = restoredPack.loadConfigFiles()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The restore is recorded too, so it can be undone
+	changes, _, err := compareSnapshots(current, snapshot)
+	if err == nil && !changes.IsEmpty() {
+		_, err = recordSnapshot(pack.folder, snapshot.Sides, changes, fmt.Sprintf("Restored %s: %s", id, changes.String()))
+	}
+	if err != nil {
+		log.Print("Error recording edit history:")
+		log.Print(err)
+	}
+
+	// Mods are loaded in the background, so other requests for this modpack aren't blocked
+	restoredPack.Mods = make(map[int]ModInfo)
+	restoredPack.LoadingMods = true
+	pack.modpack = restoredPack
+
+	// Send the modpack to the client
+	json.NewEncoder(w).Encode(struct {
+		ID      string
+		Modpack Modpack
+	}{pack.id, pack.modpack})
+
+	go pack.loadModInfoList()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots int
+		wantFirst int
+	}{
+		{"under the limit", 3, 0},
+		{"at the limit", maxSnapshots, 0},
+		{"over the limit", maxSnapshots + 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packFolder, err := ioutil.TempDir("", "modpack-editor-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(packFolder)
+			for i := 0; i < tt.snapshots; i++ {
+				err = os.MkdirAll(filepath.Join(historyFolder(packFolder), fmt.Sprintf("20200101-000000.%03d", i)), os.ModePerm)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = pruneSnapshots(packFolder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := ioutil.ReadDir(historyFolder(packFolder))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCount := tt.snapshots - tt.wantFirst
+			if len(files) != wantCount {
+				t.Fatalf("%d snapshots kept, want %d", len(files), wantCount)
+			}
+			if want := fmt.Sprintf("20200101-000000.%03d", tt.wantFirst); files[0].Name() != want {
+				t.Errorf("oldest kept snapshot is %s, want %s", files[0].Name(), want)
+			}
+		})
+	}
+}
+
+func TestIgnoreHistoryKeepsExistingFile(t *testing.T) {
+	packFolder, err := ioutil.TempDir("", "modpack-editor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(packFolder)
+	err = os.MkdirAll(filepath.Join(packFolder, editorDataFolder), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(packFolder, editorDataFolder, ".gitignore")
+
+	err = ignoreHistory(packFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "history/\n" {
+		t.Errorf("got .gitignore %q", data)
+	}
+
+	err = ioutil.WriteFile(path, []byte("*\n"), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ignoreHistory(packFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(path)
+	if string(data) != "*\n" {
+		t.Errorf("an existing .gitignore was replaced with %q", data)
+	}
+}
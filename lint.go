@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// Codes of diagnostics found by the linter
+const (
+	LintDuplicateProject      = "duplicateProject"
+	LintUnknownIgnoredProject = "unknownIgnoredProject"
+	LintNoPrimaryModLoader    = "noPrimaryModLoader"
+	LintNoMinecraftVersion    = "noMinecraftVersion"
+	LintInvalidCrashTimer     = "invalidCrashTimer"
+	LintInvalidMaxRAM         = "invalidMaxRAM"
+	LintMcVersionMismatch     = "mcVersionMismatch"
+	LintForgeVersionMismatch  = "forgeVersionMismatch"
+	LintLoaderVersionMismatch = "loaderVersionMismatch"
+)
+
+// lintRule checks a modpack for one kind of problem
+type lintRule func(m *Modpack) []Diagnostic
+
+// lintRules are run in order by lint
+var lintRules = []lintRule{
+	lintDuplicateProjects,
+	lintUnknownIgnoredProjects,
+	lintPrimaryModLoader,
+	lintMinecraftVersion,
+	lintCrashTimer,
+	lintMaxRAM,
+	lintServerVersions,
+}
+
+// crashTimerPattern is ServerStarter's crashTimer syntax: [number]h, [number]min or [number]s
+var crashTimerPattern = regexp.MustCompile(`^\d+(h|min|s)$`)
+
+// maxRAMPattern is a Java -Xmx size, such as 5G or 4096M
+var maxRAMPattern = regexp.MustCompile(`^[1-9]\d*[kKmMgG]$`)
+
+// lint checks a modpack's config files for mistakes
+func (m *Modpack) lint() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, rule := range lintRules {
+		diagnostics = append(diagnostics, rule(m)...)
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics
+}
+
+func lintDuplicateProjects(m *Modpack) []Diagnostic {
+	var diagnostics []Diagnostic
+	seen := make(map[int]bool)
+	for _, v := range m.CurseManifest.Files {
+		if seen[v.ProjectID] {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:      LintDuplicateProject,
+				Severity:  SeverityError,
+				Message:   fmt.Sprintf("Project %d is in the manifest more than once", v.ProjectID),
+				ProjectID: v.ProjectID,
+			})
+		}
+		seen[v.ProjectID] = true
+	}
+	return diagnostics
+}
+
+func lintUnknownIgnoredProjects(m *Modpack) []Diagnostic {
+	var diagnostics []Diagnostic
+	inManifest := make(map[int]bool)
+	for _, v := range m.CurseManifest.Files {
+		inManifest[v.ProjectID] = true
+	}
+	for _, v := range m.ServerSetupConfig.Install.FormatSpecific.IgnoreProject {
+		if !inManifest[v] {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:      LintUnknownIgnoredProject,
+				Severity:  SeverityWarning,
+				Message:   fmt.Sprintf("Project %d is ignored on the server, but isn't in the manifest", v),
+				ProjectID: v,
+			})
+		}
+	}
+	return diagnostics
+}
+
+func lintPrimaryModLoader(m *Modpack) []Diagnostic {
+	for _, v := range m.CurseManifest.Minecraft.ModLoaders {
+		if v.Primary {
+			return nil
+		}
+	}
+	return []Diagnostic{{
+		Code:     LintNoPrimaryModLoader,
+		Severity: SeverityError,
+		Message:  "The manifest has no primary modloader",
+	}}
+}
+
+func lintMinecraftVersion(m *Modpack) []Diagnostic {
+	if len(m.CurseManifest.Minecraft.Version) > 0 {
+		return nil
+	}
+	return []Diagnostic{{
+		Code:     LintNoMinecraftVersion,
+		Severity: SeverityError,
+		Message:  "The manifest has no Minecraft version",
+	}}
+}
+
+func lintCrashTimer(m *Modpack) []Diagnostic {
+	crashTimer := m.ServerSetupConfig.Launch.CrashTimer
+	if crashTimerPattern.MatchString(crashTimer) {
+		return nil
+	}
+	return []Diagnostic{{
+		Code:     LintInvalidCrashTimer,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("The crash timer %q should be a number followed by h, min or s, such as 60min", crashTimer),
+	}}
+}
+
+func lintMaxRAM(m *Modpack) []Diagnostic {
+	maxRAM := m.ServerSetupConfig.Launch.MaxRAM
+	if maxRAMPattern.MatchString(maxRAM) {
+		return nil
+	}
+	return []Diagnostic{{
+		Code:     LintInvalidMaxRAM,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("The maximum RAM %q should be a number followed by K, M or G, such as 5G", maxRAM),
+	}}
+}
+
+func lintServerVersions(m *Modpack) []Diagnostic {
+	return m.serverVersionDrift()
+}
+
+// lintModpack lints the modpack being edited, or the saved modpack if none is given
+func lintModpack(w http.ResponseWriter, pack *openModpack, newPack Modpack) {
+	if newPack.Mods != nil {
+		err := newPack.updateModLists()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	} else {
+		pack.mutex.RLock()
+		newPack = Modpack{
+			CurseManifest:     pack.modpack.CurseManifest,
+			ServerSetupConfig: pack.modpack.ServerSetupConfig,
+			Settings:          pack.modpack.Settings,
+		}
+		pack.mutex.RUnlock()
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Diagnostics []Diagnostic
+	}{newPack.lint()})
+}
+
+// withModDiagnostics adds the diagnostics found while loading mods to the lint results.
+// Loading mods also reports server version drift, which lint already has.
+func withModDiagnostics(diagnostics, modDiagnostics []Diagnostic) []Diagnostic {
+	for _, v := range modDiagnostics {
+		switch v.Code {
+		case LintMcVersionMismatch, LintForgeVersionMismatch, LintLoaderVersionMismatch:
+			continue
+		}
+		diagnostics = append(diagnostics, v)
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics
+}
+
+// Exit codes of the lint command
+const (
+	lintExitClean    = 0
+	lintExitErrors   = 1
+	lintExitWarnings = 2
+	lintExitFailed   = 3
+)
+
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	loadMods := flags.Bool("mods", false, "Also load every mod, and report mods that can't be found")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: modpack-editor lint [options] [folder]")
+		fmt.Fprintln(flags.Output(), "Exits with 0 if there are no problems, 1 if there are errors, 2 if there are only warnings and 3 if the modpack can't be loaded.")
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return lintExitFailed
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return lintExitFailed
+	}
+	folder := "."
+	if flags.NArg() == 1 {
+		folder = flags.Arg(0)
+	}
+
+	pack := Modpack{Folder: folder}
+	err = pack.loadConfigFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading modpack: %v\n", err)
+		return lintExitFailed
+	}
+	diagnostics := pack.lint()
+	if *loadMods {
+		pack.getModInfoList(nil)
+		flushEditorCache()
+		diagnostics = withModDiagnostics(diagnostics, pack.Diagnostics)
+	}
+
+	for _, v := range diagnostics {
+		fmt.Println(v)
+	}
+	return lintExitCode(diagnostics)
+}
+
+// lintExitCode returns the lint command's exit code for the most severe diagnostic
+func lintExitCode(diagnostics []Diagnostic) int {
+	code := lintExitClean
+	for _, v := range diagnostics {
+		if v.Severity == SeverityError {
+			code = lintExitErrors
+		} else if v.Severity == SeverityWarning && code == lintExitClean {
+			code = lintExitWarnings
+		}
+	}
+	return code
+}
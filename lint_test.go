@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModpack parses a manifest.json and server-setup-config.yaml into a modpack
+func testModpack(t *testing.T, manifest, config string) Modpack {
+	t.Helper()
+	var m Modpack
+	var err error
+	m.CurseManifest, m.ServerSetupConfig, err = parseConfigFiles([]byte(manifest), []byte(config))
+	if err != nil {
+		t.Fatalf("parsing test modpack: %v", err)
+	}
+	return m
+}
+
+const testForgeManifest = `{
+	"minecraft": {
+		"version": "1.12.2",
+		"modLoaders": [{"id": "forge-14.23.5.2854", "primary": true}]
+	},
+	"files": [{"projectID": 1, "fileID": 10, "required": true}]
+}`
+
+const testForgeConfig = `
+_specver: 1
+install:
+  mcVersion: 1.12.2
+  forgeVersion: 14.23.5.2854
+  ignoreFiles:
+    - resources/**
+launch:
+  maxRam: 5G
+  crashTimer: 60min
+`
+
+func TestLintModsReportsDriftOnce(t *testing.T) {
+	pack := testModpack(t, testForgeManifest, `
+_specver: 1
+install:
+  mcVersion: 1.12.1
+  forgeVersion: 14.23.5.2847
+launch:
+  maxRam: 5G
+  crashTimer: 60min
+`)
+	// getModInfoList reports drift along with the mods that couldn't be loaded
+	pack.Diagnostics = append(pack.serverVersionDrift(), Diagnostic{
+		Code:      DiagnosticModRequestFailed,
+		Severity:  SeverityError,
+		ProjectID: 1,
+	})
+
+	diagnostics := withModDiagnostics(pack.lint(), pack.Diagnostics)
+	counts := make(map[string]int)
+	for _, v := range diagnostics {
+		counts[v.Code]++
+	}
+	for _, code := range []string{LintMcVersionMismatch, LintForgeVersionMismatch, DiagnosticModRequestFailed} {
+		if counts[code] != 1 {
+			t.Errorf("%s reported %d times, want once: %v", code, counts[code], diagnostics)
+		}
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *Modpack)
+		want   []string
+	}{
+		{"clean", func(m *Modpack) {}, nil},
+		{"duplicate project", func(m *Modpack) {
+			m.CurseManifest.Files = append(m.CurseManifest.Files, m.CurseManifest.Files[0])
+		}, []string{LintDuplicateProject}},
+		{"unknown ignored project", func(m *Modpack) {
+			m.ServerSetupConfig.Install.FormatSpecific.IgnoreProject = []int{2}
+		}, []string{LintUnknownIgnoredProject}},
+		{"no primary modloader", func(m *Modpack) {
+			m.CurseManifest.Minecraft.ModLoaders[0].Primary = false
+		}, []string{LintNoPrimaryModLoader}},
+		{"no Minecraft version", func(m *Modpack) {
+			m.CurseManifest.Minecraft.Version = ""
+		}, []string{LintNoMinecraftVersion}},
+		{"invalid crash timer", func(m *Modpack) {
+			m.ServerSetupConfig.Launch.CrashTimer = "60 minutes"
+		}, []string{LintInvalidCrashTimer}},
+		{"invalid max RAM", func(m *Modpack) {
+			m.ServerSetupConfig.Launch.MaxRAM = "5GB"
+		}, []string{LintInvalidMaxRAM}},
+		{"server Minecraft version", func(m *Modpack) {
+			m.ServerSetupConfig.Install.McVersion = "1.12.1"
+		}, []string{LintMcVersionMismatch}},
+		{"server Forge version", func(m *Modpack) {
+			m.ServerSetupConfig.Install.ForgeVersion = "14.23.5.2847"
+		}, []string{LintForgeVersionMismatch}},
+		{"empty Forge version uses the manifest", func(m *Modpack) {
+			m.ServerSetupConfig.Install.ForgeVersion = ""
+		}, nil},
+		{"server versions set manually", func(m *Modpack) {
+			m.Settings.OverrideServerVersions = true
+			m.ServerSetupConfig.Install.McVersion = "1.12.1"
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack := testModpack(t, testForgeManifest, testForgeConfig)
+			tt.change(&pack)
+
+			var got []string
+			for _, v := range pack.lint() {
+				got = append(got, v.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintExitCode(t *testing.T) {
+	errorDiagnostic := Diagnostic{Code: LintInvalidMaxRAM, Severity: SeverityError}
+	warningDiagnostic := Diagnostic{Code: LintMcVersionMismatch, Severity: SeverityWarning}
+	infoDiagnostic := Diagnostic{Code: "info", Severity: SeverityInfo}
+	tests := []struct {
+		name        string
+		diagnostics []Diagnostic
+		want        int
+	}{
+		{"nothing", nil, lintExitClean},
+		{"info", []Diagnostic{infoDiagnostic}, lintExitClean},
+		{"warning", []Diagnostic{infoDiagnostic, warningDiagnostic}, lintExitWarnings},
+		{"error", []Diagnostic{errorDiagnostic}, lintExitErrors},
+		{"error after a warning", []Diagnostic{warningDiagnostic, errorDiagnostic}, lintExitErrors},
+		{"warning after an error", []Diagnostic{errorDiagnostic, warningDiagnostic}, lintExitErrors},
+	}
+	for _, tt := range tests {
+		if got := lintExitCode(tt.diagnostics); got != tt.want {
+			t.Errorf("%s: got exit code %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLintCommandExitCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   int
+	}{
+		{"clean", testForgeConfig, lintExitClean},
+		{"errors", strings.Replace(testForgeConfig, "maxRam: 5G", "maxRam: lots", 1), lintExitErrors},
+		{"warnings", strings.Replace(testForgeConfig, "mcVersion: 1.12.2", "mcVersion: 1.12.1", 1), lintExitWarnings},
+		{"invalid config", "install: [", lintExitFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder, err := ioutil.TempDir("", "modpack-editor-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(folder)
+			err = ioutil.WriteFile(filepath.Join(folder, "manifest.json"), []byte(testForgeManifest), 0664)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ioutil.WriteFile(filepath.Join(folder, "server-setup-config.yaml"), []byte(tt.config), 0664)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := lintCommand([]string{folder}); got != tt.want {
+				t.Errorf("got exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := lintCommand([]string{filepath.Join(os.TempDir(), "modpack-editor-missing")}); got != lintExitFailed {
+		t.Errorf("missing folder: got exit code %d, want %d", got, lintExitFailed)
+	}
+	if got := lintCommand([]string{"a", "b"}); got != lintExitFailed {
+		t.Errorf("two folders: got exit code %d, want %d", got, lintExitFailed)
+	}
+}
@@ -1,30 +1,52 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
-	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gobuffalo/packr"
 )
 
 var staticFilesBox packr.Box
 var blankPackBox packr.Box
-var modpack Modpack
-var modpackMutex sync.RWMutex
 var disableCacheStore bool
 
+// shutdownTimeout is how long to wait for requests to finish when shutting down
+const shutdownTimeout = 30 * time.Second
+
 type postRequestData struct {
-	Folder  string
-	Modpack Modpack
+	ID          string
+	Folder      string
+	Modpack     Modpack
+	Snapshot    string
+	Revision    string
+	From        string
+	To          string
+	ProjectID   int
+	MaxAge      string
+	CheckJars   bool
+	Apply       bool
+	Loader      string
+	Template    string
+	Variables   PackTemplateVariables
+	Clone       CloneOptions
+	Source      string
+	Resolutions []MergeResolution
 }
 
 func ajaxHandler(w http.ResponseWriter, r *http.Request) {
+	// data is decoded for each request, so handlers can change data.Modpack without affecting an open modpack
 	var data postRequestData
 	err := json.NewDecoder(r.Body).Decode(&data)
 	if err != nil && err != io.EOF {
@@ -34,13 +56,64 @@ func ajaxHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.URL.Path {
 	case "/ajax/getCurrentPackDetails":
-		getCurrentPackDetails(w)
+		getCurrentPackDetails(w, data.ID)
+	case "/ajax/listOpenModpacks":
+		listOpenModpacks(w)
 	case "/ajax/loadModpackFolder":
 		loadModpackFolder(w, data.Folder)
 	case "/ajax/createModpackFolder":
-		createModpackFolder(w, data.Folder)
+		createModpackFolder(w, data.Folder, data.Template, data.Variables)
+	case "/ajax/listTemplates":
+		listTemplates(w)
+	case "/ajax/closeModpack":
+		closeModpack(w, data.ID)
+	case "/ajax/getCacheStats":
+		getCacheStats(w)
+	case "/ajax/purgeCache":
+		purgeCache(w, data.MaxAge)
+	default:
+		// Everything else acts on an open modpack
+		pack, err := workspace.get(data.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		modpackAjaxHandler(w, r.URL.Path, pack, data)
+	}
+}
+
+func modpackAjaxHandler(w http.ResponseWriter, path string, pack *openModpack, data postRequestData) {
+	switch path {
 	case "/ajax/saveModpack":
-		saveModpack(w, data.Modpack)
+		saveModpack(w, pack, data.Modpack)
+	case "/ajax/previewSave":
+		previewSave(w, pack, data.Modpack)
+	case "/ajax/listHistory":
+		listHistory(w, pack)
+	case "/ajax/diffHistory":
+		diffHistory(w, pack, data.From, data.To)
+	case "/ajax/restoreHistory":
+		restoreHistory(w, pack, data.Snapshot, data.Revision)
+	case "/ajax/getChangelog":
+		getChangelog(w, pack, data.From, data.To, data.Modpack)
+	case "/ajax/refreshCache":
+		refreshCache(w, pack, data.ProjectID)
+	case "/ajax/lintModpack":
+		lintModpack(w, pack, data.Modpack)
+	case "/ajax/suggestSides":
+		suggestSidesHandler(w, data.Modpack, data.CheckJars, data.Apply)
+	case "/ajax/listModLoaderVersions":
+		listModLoaderVersions(w, data.Modpack, data.Loader)
+	case "/ajax/fillForgeInstallerURL":
+		fillForgeInstallerURL(w, data.Modpack)
+	case "/ajax/cloneModpack":
+		cloneModpack(w, pack, data.Folder, data.Clone)
+	case "/ajax/previewMerge":
+		previewMerge(w, data.Modpack, data.Source)
+	case "/ajax/applyMerge":
+		applyMergeHandler(w, data.Modpack, data.Source, data.Resolutions)
+	case "/ajax/getModFiles":
+		getModFiles(w, data.Modpack, data.ProjectID)
 	default:
 		w.WriteHeader(404)
 	}
@@ -56,10 +129,6 @@ func addonHandlerSlug(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update cache
-	// TODO: do this only on change
-	writeEditorCache()
-
 	err = json.NewEncoder(w).Encode(data)
 	if err != nil {
 		// may have already written to output?
@@ -82,10 +151,6 @@ func addonHandlerID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update cache
-	// TODO: do this only on change
-	writeEditorCache()
-
 	err = json.NewEncoder(w).Encode(data)
 	if err != nil {
 		// may have already written to output?
@@ -98,32 +163,84 @@ func main() {
 	port := flag.Int("port", 8080, "The port that the HTTP server listens on")
 	ip := flag.String("ip", "127.0.0.1", "The ip that the HTTP server listens on")
 	nocache := flag.Bool("nocache", false, "Don't store cached mod listings or modpack folders")
+	cacheSize := flag.Int("cachesize", 0, "The maximum size of the cache file in MiB, least recently used mods are removed to fit (0 for no limit)")
+	trimCache := flag.Bool("trimcache", false, "Only cache the mod details the editor uses, so the cache is smaller")
+	clientOnlyList := flag.String("clientonlylist", defaultClientOnlyListPath(), "A list of client only project IDs, used to suggest mods to remove from the server")
+	modLoaderCatalogFile := flag.String("modloadercatalog", "", "A JSON file listing modloader versions, used instead of downloading them")
+	offline := flag.Bool("offline", false, "Don't connect to the internet, only use cached mod listings")
+	cacheFile := flag.String("cachefile", "", "The file to store cached mod listings in, defaults to the user cache folder")
+	templates := flag.String("templates", defaultTemplateFolder(), "Folders containing modpack templates, separated by "+string(os.PathListSeparator))
 	flag.Parse()
 
 	staticFilesBox = packr.NewBox("./static")
 	blankPackBox = packr.NewBox("./blankPack")
 	disableCacheStore = *nocache
+	offlineMode = *offline
+	clientOnlyListPath = *clientOnlyList
+	templateFolders = filepath.SplitList(*templates)
+	if len(*modLoaderCatalogFile) > 0 {
+		modLoaderCatalog = fileModLoaderCatalog{*modLoaderCatalogFile}
+	}
+	maxCacheSize = int64(*cacheSize) * 1024 * 1024
+	trimCachedMods = *trimCache
+	editorCachePath = *cacheFile
+	if len(editorCachePath) == 0 {
+		editorCachePath = defaultEditorCachePath()
+	}
 
-	loadedModpack := loadEditorCache()
-	if loadedModpack != nil {
-		modpackMutex.Lock()
-		modpack = *loadedModpack
-		modpackMutex.Unlock()
+	loadEditorCache()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
 	}
 
+	loadOpenModpacks()
+
+	stopCacheWriter := make(chan struct{})
+	go runCacheWriter(stopCacheWriter)
+
 	fmt.Println("Welcome to modpack-editor!")
 	fmt.Printf("Listening on port %d, accessible at http://%s:%d/\n", *port, *ip, *port)
 	fmt.Println("Press CTRL+C to exit.")
 
 	http.Handle("/", http.FileServer(staticFilesBox))
 	http.HandleFunc("/ajax/", ajaxHandler)
+	http.HandleFunc("/events", eventsHandler)
 	http.HandleFunc("/addon/", addonHandlerSlug)
 	http.HandleFunc("/addonSlug/", addonHandlerID)
-	err := http.ListenAndServe(fmt.Sprintf("%s:%d", *ip, *port), nil)
-	if err != nil {
+
+	server := &http.Server{Addr: fmt.Sprintf("%s:%d", *ip, *port)}
+	// Event streams never finish by themselves
+	server.RegisterOnShutdown(events.close)
+
+	shutdownComplete := make(chan struct{})
+	go func() {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		<-interrupt
+		fmt.Println("Shutting down...")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Print("Error shutting down server:")
+			log.Print(err)
+		}
+		// Even if other requests timed out, saves must finish
+		saveLock.Lock()
+
+		close(stopCacheWriter)
+		flushEditorCache()
+		close(shutdownComplete)
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		log.Println("Error starting server:")
 		log.Fatal(err)
 	}
+	<-shutdownComplete
 }
 
 func writeError(w http.ResponseWriter, e error) {
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// MergeEntry is a project in the other modpack that is new, or differs from the modpack being edited
+type MergeEntry struct {
+	ProjectID int
+	Name      string
+	// The current values are zero for projects that are only in the other modpack
+	CurrentFileID   int
+	OtherFileID     int
+	CurrentOnClient bool
+	CurrentOnServer bool
+	OtherOnClient   bool
+	OtherOnServer   bool
+}
+
+// MergePreview lists what merging another modpack would change
+type MergePreview struct {
+	// Added are projects only in the other modpack
+	Added []MergeEntry
+	// DifferentFiles are projects in both modpacks with different files
+	DifferentFiles []MergeEntry
+	// DifferentSides are projects in both modpacks on different sides
+	DifferentSides []MergeEntry
+	Diagnostics    []Diagnostic
+}
+
+// MergeResolution chooses what to take from the other modpack for a project.
+// Projects only in the other modpack are added with its file and sides if either is true.
+type MergeResolution struct {
+	ProjectID int
+	File      bool
+	Sides     bool
+}
+
+// loadMergeSource loads the mods in another modpack folder, or a modpack zip.
+// Zips without a server-setup-config.yaml have every mod on both sides.
+func loadMergeSource(source string) (Modpack, error) {
+	var other Modpack
+	stat, err := os.Stat(source)
+	if err != nil {
+		return other, err
+	}
+
+	var manifest, config []byte
+	if stat.IsDir() {
+		manifest, config, err = readConfigFiles(source)
+	} else {
+		manifest, err = readManifestZip(source)
+		if err == nil {
+			config, _, err = readZipFile(source, "server-setup-config.yaml")
+		}
+	}
+	if err != nil {
+		return other, err
+	}
+	other.CurseManifest, other.ServerSetupConfig, err = parseConfigFiles(manifest, config)
+	if err != nil {
+		return other, err
+	}
+
+	// Server versions aren't merged, so don't report them
+	other.Settings.OverrideServerVersions = true
+	other.getModInfoList(nil)
+	return other, nil
+}
+
+// mergePreview compares the mods in another modpack with the mods being edited
+func (m *Modpack) mergePreview(other Modpack) MergePreview {
+	preview := MergePreview{Diagnostics: other.Diagnostics}
+	for projectID, otherMod := range other.Mods {
+		if otherMod.Error != nil {
+			// Already in the diagnostics
+			continue
+		}
+		entry := MergeEntry{
+			ProjectID:     projectID,
+			Name:          otherMod.Name,
+			OtherFileID:   otherMod.FileID,
+			OtherOnClient: otherMod.OnClient,
+			OtherOnServer: otherMod.OnServer,
+		}
+
+		mod, ok := m.Mods[projectID]
+		if !ok {
+			preview.Added = append(preview.Added, entry)
+			preview.Diagnostics = append(preview.Diagnostics, m.mergeFileDiagnostics(projectID, otherMod.FileID)...)
+			continue
+		}
+		if mod.Error != nil {
+			continue
+		}
+		entry.CurrentFileID = mod.FileID
+		entry.CurrentOnClient = mod.OnClient
+		entry.CurrentOnServer = mod.OnServer
+		if mod.FileID != otherMod.FileID {
+			preview.DifferentFiles = append(preview.DifferentFiles, entry)
+			preview.Diagnostics = append(preview.Diagnostics, m.mergeFileDiagnostics(projectID, otherMod.FileID)...)
+		}
+		if mod.OnClient != otherMod.OnClient || mod.OnServer != otherMod.OnServer {
+			preview.DifferentSides = append(preview.DifferentSides, entry)
+		}
+	}
+
+	for _, entries := range [][]MergeEntry{preview.Added, preview.DifferentFiles, preview.DifferentSides} {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+	}
+	sortDiagnostics(preview.Diagnostics)
+	return preview
+}
+
+// mergeFileDiagnostics reports a file from the other modpack that isn't for this modpack's modloader
+func (m *Modpack) mergeFileDiagnostics(projectID, fileID int) []Diagnostic {
+	file, err := requestFileData(projectID, fileID)
+	if err != nil {
+		// The other modpack loaded this file, so it is cached
+		return nil
+	}
+	if diagnostic := m.incompatibleFileDiagnostic(projectID, file); diagnostic != nil {
+		return []Diagnostic{*diagnostic}
+	}
+	return nil
+}
+
+// applyMerge takes the chosen files and sides from the other modpack
+func (m *Modpack) applyMerge(other Modpack, resolutions []MergeResolution) {
+	for _, v := range resolutions {
+		otherMod, ok := other.Mods[v.ProjectID]
+		if !ok || otherMod.Error != nil || (!v.File && !v.Sides) {
+			continue
+		}
+
+		mod, ok := m.Mods[v.ProjectID]
+		if !ok {
+			m.Mods[v.ProjectID] = otherMod
+			continue
+		}
+		if v.File {
+			mod.FileID = otherMod.FileID
+			mod.Dependencies = otherMod.Dependencies
+		}
+		if v.Sides {
+			mod.OnClient = otherMod.OnClient
+			mod.OnServer = otherMod.OnServer
+		}
+		m.Mods[v.ProjectID] = mod
+	}
+}
+
+// previewMerge lists the differences between the modpack being edited and another modpack
+func previewMerge(w http.ResponseWriter, newPack Modpack, source string) {
+	if newPack.Mods == nil {
+		writeError(w, errors.New("No mods given"))
+		return
+	}
+	other, err := loadMergeSource(source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newPack.mergePreview(other))
+}
+
+// applyMergeHandler applies the resolutions to the modpack being edited, and returns the new mod list.
+// The mod list is checked by updating the config files, but isn't saved.
+func applyMergeHandler(w http.ResponseWriter, newPack Modpack, source string, resolutions []MergeResolution) {
+	if newPack.Mods == nil {
+		writeError(w, errors.New("No mods given"))
+		return
+	}
+	other, err := loadMergeSource(source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	newPack.applyMerge(other, resolutions)
+	err = newPack.updateModLists()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Mods map[int]ModInfo
+	}{newPack.Mods})
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModLoaderVersion is a modloader version available for a Minecraft version
+type ModLoaderVersion struct {
+	// ID is the modloader ID used in the manifest, such as forge-14.23.5.2768
+	ID               string
+	Version          string
+	MinecraftVersion string
+	Recommended      bool
+	Latest           bool
+	InstallerURL     string
+}
+
+// ModLoaderCatalog lists the versions of modloaders
+type ModLoaderCatalog interface {
+	// Versions lists the versions of a loader for a Minecraft version, newest first
+	Versions(loader, mcVersion string) ([]ModLoaderVersion, error)
+}
+
+// modLoaderCatalog is where modloader versions are looked up
+var modLoaderCatalog ModLoaderCatalog = networkModLoaderCatalog{}
+
+// Codes of diagnostics found by checking modloaders against the catalog
+const (
+	DiagnosticUnknownModLoader     = "unknownModLoader"
+	DiagnosticNotRecommendedLoader = "notRecommendedModLoader"
+)
+
+const forgeMavenURL = "https://maven.minecraftforge.net/net/minecraftforge/forge/"
+
+// forgeInstallerURLTemplate is the default installer URL, {version} is the full Maven version
+const forgeInstallerURLTemplate = forgeMavenURL + "{version}/forge-{version}-installer.jar"
+
+// forgeMetadataMaxAge is how long the Forge version list is kept before it is downloaded again
+const forgeMetadataMaxAge = time.Hour
+
+// networkModLoaderCatalog downloads modloader versions from the modloader's website
+type networkModLoaderCatalog struct{}
+
+// forgeMetadata caches the downloaded Forge version list
+var forgeMetadata struct {
+	mutex sync.Mutex
+	// versions are the full Maven versions, such as 1.12.2-14.23.5.2768
+	versions   []string
+	promotions map[string]string
+	fetched    time.Time
+}
+
+func (networkModLoaderCatalog) Versions(loader, mcVersion string) ([]ModLoaderVersion, error) {
+	switch loader {
+	case "forge":
+		return forgeVersions(mcVersion)
+	case "fabric":
+		return fabricVersions(mcVersion)
+	default:
+		return nil, fmt.Errorf("Modloader %s isn't supported", loader)
+	}
+}
+
+func forgeVersions(mcVersion string) ([]ModLoaderVersion, error) {
+	forgeMetadata.mutex.Lock()
+	defer forgeMetadata.mutex.Unlock()
+	if time.Since(forgeMetadata.fetched) > forgeMetadataMaxAge {
+		err := fetchForgeMetadata()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recommended := forgeMetadata.promotions[mcVersion+"-recommended"]
+	latest := forgeMetadata.promotions[mcVersion+"-latest"]
+	var versions []ModLoaderVersion
+	// Maven lists versions oldest first
+	for i := len(forgeMetadata.versions) - 1; i >= 0; i-- {
+		fullVersion := forgeMetadata.versions[i]
+		if !strings.HasPrefix(fullVersion, mcVersion+"-") {
+			continue
+		}
+		// Some old versions also end with the Minecraft version
+		version := strings.TrimSuffix(strings.TrimPrefix(fullVersion, mcVersion+"-"), "-"+mcVersion)
+		versions = append(versions, ModLoaderVersion{
+			ID:               "forge-" + version,
+			Version:          version,
+			MinecraftVersion: mcVersion,
+			Recommended:      version == recommended,
+			Latest:           version == latest,
+			InstallerURL:     strings.Replace(forgeInstallerURLTemplate, "{version}", fullVersion, -1),
+		})
+	}
+	if len(versions) > 0 && len(latest) == 0 {
+		versions[0].Latest = true
+	}
+	return versions, nil
+}
+
+// fetchForgeMetadata downloads the Forge version list. The forgeMetadata mutex must be held.
+func fetchForgeMetadata() error {
+	if offlineMode {
+		return errors.New("Can't download the Forge version list in offline mode, use -modloadercatalog to give a catalog file")
+	}
+
+	var metadata struct {
+		Versions []string `xml:"versioning>versions>version"`
+	}
+	err := getModLoaderData(forgeMavenURL+"maven-metadata.xml", func(data []byte) error {
+		return xml.Unmarshal(data, &metadata)
+	})
+	if err != nil {
+		return err
+	}
+
+	var promotions struct {
+		Promos map[string]string `json:"promos"`
+	}
+	err = getModLoaderData("https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json", func(data []byte) error {
+		return json.Unmarshal(data, &promotions)
+	})
+	if err != nil {
+		return err
+	}
+
+	forgeMetadata.versions = metadata.Versions
+	forgeMetadata.promotions = promotions.Promos
+	forgeMetadata.fetched = time.Now()
+	return nil
+}
+
+// fabricVersions downloads the Fabric loader versions for a Minecraft version.
+// The newest is marked as latest, and the newest stable version as recommended.
+func fabricVersions(mcVersion string) ([]ModLoaderVersion, error) {
+	if offlineMode {
+		return nil, errors.New("Can't download the Fabric version list in offline mode, use -modloadercatalog to give a catalog file")
+	}
+
+	var loaders []struct {
+		Loader struct {
+			Version string `json:"version"`
+			Stable  bool   `json:"stable"`
+		} `json:"loader"`
+	}
+	err := getModLoaderData("https://meta.fabricmc.net/v2/versions/loader/"+url.PathEscape(mcVersion), func(data []byte) error {
+		return json.Unmarshal(data, &loaders)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]ModLoaderVersion, 0, len(loaders))
+	foundStable := false
+	for i, v := range loaders {
+		versions = append(versions, ModLoaderVersion{
+			ID:               "fabric-" + v.Loader.Version,
+			Version:          v.Loader.Version,
+			MinecraftVersion: mcVersion,
+			Recommended:      v.Loader.Stable && !foundStable,
+			Latest:           i == 0,
+			InstallerURL:     fabricInstallerURL,
+		})
+		foundStable = foundStable || v.Loader.Stable
+	}
+	return versions, nil
+}
+
+// getModLoaderData downloads a file and parses it with decode
+func getModLoaderData(fileURL string, decode func(data []byte) error) error {
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "comp500/modpack-editor client")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Downloading %s failed: %s", fileURL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return decode(data)
+}
+
+// fileModLoaderCatalog reads modloader versions from a JSON file, for offline use.
+// It maps loaders to Minecraft versions to a modLoaderCatalogEntry, the README has an example.
+type fileModLoaderCatalog struct {
+	path string
+}
+
+// modLoaderCatalogEntry lists the versions of a loader for one Minecraft version.
+// InstallerURL can contain {mcVersion} and {version}, and defaults to Forge's Maven for Forge
+// and the Fabric installer for Fabric.
+type modLoaderCatalogEntry struct {
+	Recommended  string   `json:"recommended"`
+	Latest       string   `json:"latest"`
+	Versions     []string `json:"versions"`
+	InstallerURL string   `json:"installerUrl"`
+}
+
+func (c fileModLoaderCatalog) Versions(loader, mcVersion string) ([]ModLoaderVersion, error) {
+	// Read every time, so the file can be updated without restarting
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog map[string]map[string]modLoaderCatalogEntry
+	err = json.Unmarshal(data, &catalog)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading modloader catalog: %v", err)
+	}
+
+	entry, ok := catalog[loader][mcVersion]
+	if !ok {
+		if _, ok := catalog[loader]; !ok {
+			return nil, fmt.Errorf("Modloader %s isn't in the catalog", loader)
+		}
+		return nil, nil
+	}
+	installerURL := entry.InstallerURL
+	if len(installerURL) == 0 && loader == "forge" {
+		installerURL = strings.Replace(forgeInstallerURLTemplate, "{version}", "{mcVersion}-{version}", -1)
+	} else if len(installerURL) == 0 && loader == "fabric" {
+		installerURL = fabricInstallerURL
+	}
+
+	versions := make([]ModLoaderVersion, 0, len(entry.Versions))
+	for _, v := range entry.Versions {
+		versions = append(versions, ModLoaderVersion{
+			ID:               loader + "-" + v,
+			Version:          v,
+			MinecraftVersion: mcVersion,
+			Recommended:      v == entry.Recommended,
+			Latest:           v == entry.Latest,
+			InstallerURL:     strings.NewReplacer("{mcVersion}", mcVersion, "{version}", v).Replace(installerURL),
+		})
+	}
+	return versions, nil
+}
+
+// findModLoaderVersion returns the catalog entry for a modloader ID in the modpack
+func (m *Modpack) findModLoaderVersion(id string) (*ModLoaderVersion, []ModLoaderVersion, error) {
+	loader, version := parseModLoaderID(id)
+	versions, err := modLoaderCatalog.Versions(loader, m.CurseManifest.Minecraft.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, v := range versions {
+		if v.Version == version {
+			return &versions[i], versions, nil
+		}
+	}
+	return nil, versions, nil
+}
+
+// checkModLoaders reports manifest modloaders that aren't in the catalog, or aren't the recommended version
+func (m *Modpack) checkModLoaders() ([]Diagnostic, error) {
+	var diagnostics []Diagnostic
+	for _, v := range m.CurseManifest.Minecraft.ModLoaders {
+		found, versions, err := m.findModLoaderVersion(v.ID)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     DiagnosticUnknownModLoader,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s isn't a known modloader version for Minecraft %s", v.ID, m.CurseManifest.Minecraft.Version),
+			})
+			continue
+		}
+		if found.Recommended {
+			continue
+		}
+		for _, version := range versions {
+			if version.Recommended {
+				diagnostics = append(diagnostics, Diagnostic{
+					Code:     DiagnosticNotRecommendedLoader,
+					Severity: SeverityInfo,
+					Message:  fmt.Sprintf("%s isn't the recommended version, which is %s", v.ID, version.ID),
+				})
+				break
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+// listModLoaderVersions lists versions of a loader, or the primary modloader, for the modpack's Minecraft version
+func listModLoaderVersions(w http.ResponseWriter, newPack Modpack, loader string) {
+	if len(loader) == 0 {
+		loader = "forge"
+		if primary, _, ok := newPack.primaryModLoader(); ok {
+			loader = primary
+		}
+	}
+	if len(newPack.CurseManifest.Minecraft.Version) == 0 {
+		writeError(w, errors.New("Set the Minecraft version first"))
+		return
+	}
+
+	versions, err := modLoaderCatalog.Versions(loader, newPack.CurseManifest.Minecraft.Version)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	diagnostics, err := newPack.checkModLoaders()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Versions    []ModLoaderVersion
+		Diagnostics []Diagnostic
+	}{versions, diagnostics})
+}
+
+// fillForgeInstallerURL sets ForgeInstallerURL to the installer of the primary Forge version
+func fillForgeInstallerURL(w http.ResponseWriter, newPack Modpack) {
+	for _, v := range newPack.CurseManifest.Minecraft.ModLoaders {
+		if !v.Primary {
+			continue
+		}
+		if loader, _ := parseModLoaderID(v.ID); loader != "forge" {
+			break
+		}
+		found, _, err := newPack.findModLoaderVersion(v.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if found == nil {
+			writeError(w, fmt.Errorf("%s isn't a known Forge version for Minecraft %s", v.ID, newPack.CurseManifest.Minecraft.Version))
+			return
+		}
+
+		json.NewEncoder(w).Encode(struct {
+			ForgeInstallerURL string
+		}{found.InstallerURL})
+		return
+	}
+	writeError(w, errors.New("The primary modloader isn't Forge"))
+}
+
+// loaderGameVersions are the GameVersion tags CurseForge uses for modloaders
+var loaderGameVersions = map[string]string{
+	"Forge":    "forge",
+	"Fabric":   "fabric",
+	"Quilt":    "quilt",
+	"NeoForge": "neoforge",
+}
+
+// fileMatchesLoader returns true if a file is tagged with the loader and Minecraft version.
+// Files without a loader tag are treated as Forge files, as old Forge files weren't tagged.
+func fileMatchesLoader(file FileData, loader, mcVersion string) bool {
+	hasLoaderTag, loaderMatches, versionMatches := false, false, len(mcVersion) == 0
+	for _, v := range file.GameVersion {
+		if tagLoader, ok := loaderGameVersions[v]; ok {
+			hasLoaderTag = true
+			loaderMatches = loaderMatches || tagLoader == loader
+		} else if v == mcVersion {
+			versionMatches = true
+		}
+	}
+	if !hasLoaderTag {
+		loaderMatches = loader == "forge"
+	}
+	return loaderMatches && versionMatches
+}
+
+// incompatibleFileDiagnostic reports a file that isn't for the primary modloader, or nil if it is
+func (m *Modpack) incompatibleFileDiagnostic(projectID int, file FileData) *Diagnostic {
+	loader, _, ok := m.primaryModLoader()
+	if !ok || fileMatchesLoader(file, loader, "") {
+		return nil
+	}
+	return &Diagnostic{
+		Code:      DiagnosticIncompatibleFile,
+		Severity:  SeverityWarning,
+		Message:   fmt.Sprintf("%s isn't tagged for %s", file.FileName, modLoaderName(loader)),
+		ProjectID: projectID,
+	}
+}
+
+// getModFiles lists the files of a project that can be used with the modpack's modloader and Minecraft version
+func getModFiles(w http.ResponseWriter, newPack Modpack, projectID int) {
+	if projectID == 0 {
+		writeError(w, errors.New("No project ID given"))
+		return
+	}
+	loader, _, ok := newPack.primaryModLoader()
+	if !ok {
+		writeError(w, errors.New("The manifest has no primary modloader"))
+		return
+	}
+	data, err := requestAddonData(projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	files := []FileData{}
+	for _, v := range data.LatestFiles {
+		if fileMatchesLoader(v, loader, newPack.CurseManifest.Minecraft.Version) {
+			files = append(files, v)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FileDate > files[j].FileDate
+	})
+
+	json.NewEncoder(w).Encode(struct {
+		Files []FileData
+	}{files})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFileMatchesLoader(t *testing.T) {
+	tests := []struct {
+		name        string
+		gameVersion []string
+		loader      string
+		mcVersion   string
+		want        bool
+	}{
+		{"Forge file", []string{"1.16.5", "Forge"}, "forge", "1.16.5", true},
+		{"Fabric file in a Forge pack", []string{"1.16.5", "Fabric"}, "forge", "1.16.5", false},
+		{"Fabric file", []string{"1.16.5", "Fabric"}, "fabric", "1.16.5", true},
+		{"file for both loaders", []string{"1.16.5", "Forge", "Fabric"}, "fabric", "1.16.5", true},
+		{"untagged file is Forge", []string{"1.12.2"}, "forge", "1.12.2", true},
+		{"untagged file isn't Fabric", []string{"1.12.2"}, "fabric", "1.12.2", false},
+		{"other Minecraft version", []string{"1.16.4", "Forge"}, "forge", "1.16.5", false},
+		{"any Minecraft version", []string{"1.16.4", "Forge"}, "forge", "", true},
+		{"NeoForge file in a Forge pack", []string{"1.20.1", "NeoForge"}, "forge", "1.20.1", false},
+	}
+	for _, tt := range tests {
+		file := FileData{GameVersion: tt.gameVersion}
+		if got := fileMatchesLoader(file, tt.loader, tt.mcVersion); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
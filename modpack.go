@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,7 +15,6 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/gobuffalo/packr"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -25,6 +24,17 @@ type Modpack struct {
 	CurseManifest     CurseManifest
 	ServerSetupConfig ServerSetupConfig
 	Mods              map[int]ModInfo
+	// Diagnostics are problems found while loading the mod list, and version drift
+	Diagnostics []Diagnostic
+	Settings    PackSettings
+	// LoadingMods is true while the mod list is loaded in the background
+	LoadingMods bool
+	// Revision identifies the config files this was loaded from, so saves can detect other changes
+	Revision string
+
+	// The config files at Revision
+	diskManifest []byte
+	diskConfig   []byte
 }
 
 // CurseManifest is a curse manifest.json file
@@ -79,6 +89,12 @@ type ServerSetupConfig struct {
 		CheckFolder        bool   `yaml:"checkFolder"`
 		InstallForge       bool   `yaml:"installForge"`
 		SpongeBootstrapper string `yaml:"spongeBootstrapper"`
+		// Modloader settings used instead of the Forge ones since ServerStarter spec version 2, for Fabric
+		LoaderVersion      string   `yaml:"loaderVersion,omitempty"`
+		InstallerURL       string   `yaml:"installerUrl,omitempty"`
+		InstallerArguments []string `yaml:"installerArguments,omitempty"`
+		// InstallLoader is nil if the key isn't in the file, so it isn't added to spec version 1 configs
+		InstallLoader *bool `yaml:"installLoader,omitempty"`
 	} `yaml:"install"`
 	Launch struct {
 		SpongeFix    bool     `yaml:"spongefix"`
@@ -93,24 +109,46 @@ type ServerSetupConfig struct {
 }
 
 func (m *Modpack) loadConfigFiles() error {
-	manifest, err := ioutil.ReadFile(filepath.Join(m.Folder, "manifest.json"))
+	manifest, config, err := readConfigFiles(m.Folder)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(manifest, &m.CurseManifest)
+	m.CurseManifest, m.ServerSetupConfig, err = parseConfigFiles(manifest, config)
 	if err != nil {
 		return err
 	}
+	m.Revision = packRevision(manifest, config)
+	m.diskManifest = manifest
+	m.diskConfig = config
+	m.Settings, err = loadPackSettings(m.Folder)
+	return err
+}
 
-	config, err := ioutil.ReadFile(filepath.Join(m.Folder, "server-setup-config.yaml"))
+// readConfigFiles reads the raw manifest.json and server-setup-config.yaml from a modpack folder
+func readConfigFiles(folder string) ([]byte, []byte, error) {
+	manifest, err := ioutil.ReadFile(filepath.Join(folder, "manifest.json"))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	err = yaml.Unmarshal(config, &m.ServerSetupConfig)
+	config, err := ioutil.ReadFile(filepath.Join(folder, "server-setup-config.yaml"))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+	return manifest, config, nil
+}
+
+func parseConfigFiles(manifest, config []byte) (CurseManifest, ServerSetupConfig, error) {
+	var curseManifest CurseManifest
+	var serverSetupConfig ServerSetupConfig
+	err := json.Unmarshal(manifest, &curseManifest)
+	if err != nil {
+		return curseManifest, serverSetupConfig, err
+	}
+	err = yaml.Unmarshal(config, &serverSetupConfig)
+	if err != nil {
+		return curseManifest, serverSetupConfig, err
+	}
+	return curseManifest, serverSetupConfig, nil
 }
 
 func loadModpackFolder(w http.ResponseWriter, folder string) {
@@ -120,35 +158,44 @@ func loadModpackFolder(w http.ResponseWriter, folder string) {
 		return
 	}
 
-	modpackMutex.Lock()
-	defer modpackMutex.Unlock()
-
-	modpack = Modpack{Folder: folderAbsolute}
-	err = modpack.loadConfigFiles()
+	newPack := Modpack{Folder: folderAbsolute}
+	err = newPack.loadConfigFiles()
 	if err != nil {
 		writeError(w, err)
-		// Clear value
-		modpack = Modpack{}
 		return
 	}
-	// Update mod list
-	modpack.getModInfoList()
+	// The mod list is sent to the client as it loads
+	newPack.Mods = make(map[int]ModInfo)
+	newPack.LoadingMods = true
 
-	// Update cache
-	writeEditorCache()
+	id := workspace.open(newPack)
+	pack, err := workspace.get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
 	// Send the modpack to the client
 	json.NewEncoder(w).Encode(struct {
+		ID      string
 		Modpack Modpack
-	}{modpack})
+	}{id, newPack})
+
+	go pack.loadModInfoList()
 }
 
-func createModpackFolder(w http.ResponseWriter, folder string) {
+// createModpackFolder creates a modpack from a template, with the variables filled in
+func createModpackFolder(w http.ResponseWriter, folder, templateName string, variables PackTemplateVariables) {
 	folderAbsolute, err := filepath.Abs(folder)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
+	packTemplate, err := findPackTemplate(templateName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
 	// If pack exists, stop
 	if stat, err := os.Stat(folderAbsolute); err == nil && stat.IsDir() {
@@ -164,78 +211,56 @@ func createModpackFolder(w http.ResponseWriter, folder string) {
 	}
 
 	// Copy all the files to the new folder
-	err = blankPackBox.Walk(func(fileName string, file packr.File) error {
-		out, err := os.Create(filepath.Join(folderAbsolute, fileName))
-		if err != nil {
-			return err
-		}
-		defer out.Close()
-
-		_, err = io.Copy(out, file)
-		if err != nil {
-			return err
-		}
-		return out.Close()
-	})
+	err = packTemplate.copyTo(folderAbsolute, variables)
 	if err != nil {
+		// The folder didn't exist before, so don't leave half a modpack behind
+		os.RemoveAll(folderAbsolute)
 		writeError(w, err)
 		return
 	}
 
-	modpackMutex.Lock()
-	defer modpackMutex.Unlock()
-
-	modpack = Modpack{Folder: folderAbsolute}
-	err = modpack.loadConfigFiles()
+	newPack := Modpack{Folder: folderAbsolute}
+	err = newPack.loadConfigFiles()
+	if err == nil {
+		err = newPack.saveTemplateVariables(packTemplate, variables)
+	}
 	if err != nil {
+		os.RemoveAll(folderAbsolute)
 		writeError(w, err)
-		// Clear value
-		modpack = Modpack{}
 		return
 	}
 	// Create empty modlist
-	modpack.Mods = make(map[int]ModInfo)
+	newPack.Mods = make(map[int]ModInfo)
 
-	// Update cache
-	writeEditorCache()
+	id := workspace.open(newPack)
 
 	// Send the modpack to the client
 	json.NewEncoder(w).Encode(struct {
+		ID      string
 		Modpack Modpack
-	}{modpack})
-}
-
-func getCurrentPackDetails(w io.Writer) {
-	modpackMutex.RLock()
-	defer modpackMutex.RUnlock()
-
-	if modpack.Folder == "" { // Empty modpack
-		json.NewEncoder(w).Encode(struct {
-			Modpack []byte
-		}{nil})
-	} else {
-		// Send the modpack to the client
-		json.NewEncoder(w).Encode(struct {
-			Modpack Modpack
-		}{modpack})
-	}
+	}{id, newPack})
 }
 
 // ModInfo is a partial mod information struct used for listing mods
 type ModInfo struct {
-	Name         string
-	IconURL      string
-	ErrorMessage error
-	// TODO: Required?
+	Name    string
+	IconURL string
+	// Error is set if the mod couldn't be loaded
+	Error *Diagnostic
 	// TODO: dates, rating, download counts?
 	// TODO: categories?
 	// TODO: author(s)?
-	Summary      string
-	WebsiteURL   string
-	Slug         string
-	OnClient     bool
-	OnServer     bool
-	FileID       int
+	Summary    string
+	WebsiteURL string
+	Slug       string
+	OnClient   bool
+	OnServer   bool
+	FileID     int
+	// Required is false for optional mods, which launchers let players turn off.
+	// Server only mods aren't in the manifest, so they are required if they are added to the client.
+	Required bool
+	// Stale is true if the cached info is out of date, but couldn't be updated in offline mode
+	Stale        bool
 	Dependencies []struct {
 		AddonID int    `json:"addOnId"`
 		Type    string `json:"type"`
@@ -246,27 +271,86 @@ type ModInfo struct {
 	}
 }
 
-func (m *Modpack) getModInfoList() {
+// UnmarshalJSON decodes a ModInfo, treating mods sent without Required as required
+func (mi *ModInfo) UnmarshalJSON(data []byte) error {
+	// modInfo doesn't have this method, so it is decoded normally
+	type modInfo ModInfo
+	decoded := modInfo{Required: true}
+	err := json.Unmarshal(data, &decoded)
+	*mi = ModInfo(decoded)
+	return err
+}
+
+// ModInfoProgress is sent by getModInfoList as each mod is loaded
+type ModInfoProgress struct {
+	ProjectID int
+	ModInfo   ModInfo
+	Done      int
+	Failed    int
+	Remaining int
+}
+
+// getModInfoList fetches information about every mod in the modpack.
+// If progress is not nil, it is called as each mod is loaded.
+func (m *Modpack) getModInfoList(progress func(ModInfoProgress)) {
 	info := make(map[int]ModInfo)
+	var diagnostics []Diagnostic
 	var wg sync.WaitGroup
-	// Mutex for the ModInfo map
+	// Mutex for the ModInfo map, diagnostics and progress counts
 	var mutex = &sync.RWMutex{}
 
+	remaining := len(m.CurseManifest.Files)
+	for _, v := range m.ServerSetupConfig.Install.AdditionalFiles {
+		if strings.HasPrefix(v.URL, "https://minecraft.curseforge.com/projects/") {
+			remaining++
+		}
+	}
+	done, failed := 0, 0
+	// setInfo stores the ModInfo of a project and reports progress. Failures without a project ID use 0.
+	setInfo := func(projectID int, modInfo ModInfo, ok bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if projectID != 0 {
+			info[projectID] = modInfo
+		}
+		if modInfo.Error != nil {
+			diagnostics = append(diagnostics, *modInfo.Error)
+		}
+		remaining--
+		if ok {
+			done++
+		} else {
+			failed++
+		}
+		if progress != nil {
+			progress(ModInfoProgress{projectID, modInfo, done, failed, remaining})
+		}
+	}
+	// addDiagnostic records a problem with a mod that was still loaded
+	addDiagnostic := func(diagnostic Diagnostic) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
 	for _, v := range m.CurseManifest.Files {
 		// Increment the WaitGroup counter.
 		wg.Add(1)
 
-		go func(projectID, fileID int) {
+		go func(projectID, fileID int, required bool) {
 			// Decrement the counter when the goroutine completes.
 			defer wg.Done()
 
 			data, err := requestAddonData(projectID)
 			if err != nil {
-				mutex.Lock()
-				info[projectID] = ModInfo{
-					ErrorMessage: err,
-				}
-				mutex.Unlock()
+				setInfo(projectID, ModInfo{
+					Error: &Diagnostic{
+						Code:      DiagnosticModRequestFailed,
+						Severity:  SeverityError,
+						Message:   err.Error(),
+						ProjectID: projectID,
+					},
+				}, false)
 				return
 			}
 
@@ -295,16 +379,21 @@ func (m *Modpack) getModInfoList() {
 
 			fileInfo, err := requestFileData(projectID, fileID)
 			if err != nil {
-				mutex.Lock()
-				info[projectID] = ModInfo{
-					ErrorMessage: err,
-				}
-				mutex.Unlock()
+				setInfo(projectID, ModInfo{
+					Error: &Diagnostic{
+						Code:      DiagnosticFileRequestFailed,
+						Severity:  SeverityError,
+						Message:   err.Error(),
+						ProjectID: projectID,
+					},
+				}, false)
 				return
 			}
+			if diagnostic := m.incompatibleFileDiagnostic(projectID, fileInfo); diagnostic != nil {
+				addDiagnostic(*diagnostic)
+			}
 
-			mutex.Lock()
-			info[projectID] = ModInfo{
+			setInfo(projectID, ModInfo{
 				Name:         data.Name,
 				IconURL:      iconURL,
 				Summary:      data.Summary,
@@ -313,10 +402,11 @@ func (m *Modpack) getModInfoList() {
 				OnClient:     true,
 				OnServer:     onServer,
 				FileID:       fileID,
+				Required:     required,
+				Stale:        data.isStale(),
 				Dependencies: fileInfo.Dependencies,
-			}
-			mutex.Unlock()
-		}(v.ProjectID, v.FileID)
+			}, true)
+		}(v.ProjectID, v.FileID, v.Required)
 	}
 
 	for _, v := range m.ServerSetupConfig.Install.AdditionalFiles {
@@ -334,24 +424,35 @@ func (m *Modpack) getModInfoList() {
 
 			re := regexp.MustCompile("https://minecraft.curseforge.com/projects/([\\w\\-]+)/files/(\\d+)/")
 			matches := re.FindSubmatch([]byte(projectURL))
+			invalidURL := ModInfo{
+				Error: &Diagnostic{
+					Code:     DiagnosticInvalidFileURL,
+					Severity: SeverityWarning,
+					Message:  "Couldn't read the project and file from this CurseForge URL, it should look like https://minecraft.curseforge.com/projects/<project>/files/<file ID>/download",
+					URL:      projectURL,
+				},
+			}
 			if len(matches) < 3 {
-				// TODO: where is this output?
+				setInfo(0, invalidURL, false)
 				return
 			}
 			slug := string(matches[1])
 			fileID, err := strconv.Atoi(string(matches[2]))
 			if err != nil {
+				setInfo(0, invalidURL, false)
 				return
 			}
 
 			data, err := requestAddonDataFromSlug(slug)
 			if err != nil {
-				/*mutex.Lock()
-				// TODO: where is this output?
-				info[] = ModInfo{
-					ErrorMessage: err,
-				}
-				mutex.Unlock()*/
+				setInfo(0, ModInfo{
+					Error: &Diagnostic{
+						Code:     DiagnosticSlugLookupFailed,
+						Severity: SeverityError,
+						Message:  fmt.Sprintf("Couldn't find the project %s: %v", slug, err),
+						URL:      projectURL,
+					},
+				}, false)
 				return
 			}
 
@@ -372,16 +473,19 @@ func (m *Modpack) getModInfoList() {
 
 			fileInfo, err := requestFileData(data.ID, fileID)
 			if err != nil {
-				mutex.Lock()
-				info[data.ID] = ModInfo{
-					ErrorMessage: err,
-				}
-				mutex.Unlock()
+				setInfo(data.ID, ModInfo{
+					Error: &Diagnostic{
+						Code:      DiagnosticFileRequestFailed,
+						Severity:  SeverityError,
+						Message:   err.Error(),
+						ProjectID: data.ID,
+						URL:       projectURL,
+					},
+				}, false)
 				return
 			}
 
-			mutex.Lock()
-			info[data.ID] = ModInfo{
+			setInfo(data.ID, ModInfo{
 				Name:         data.Name,
 				IconURL:      iconURL,
 				Summary:      data.Summary,
@@ -390,18 +494,16 @@ func (m *Modpack) getModInfoList() {
 				OnClient:     false,
 				OnServer:     true,
 				FileID:       fileID,
+				Required:     true,
+				Stale:        data.isStale(),
 				Dependencies: fileInfo.Dependencies,
-			}
-			mutex.Unlock()
+			}, true)
 		}(v.URL)
 	}
 
 	// Wait for all HTTP fetches to complete.
 	wg.Wait()
 
-	// Update cache
-	writeEditorCache()
-
 	// After modInfos are populated, calculate dependants
 	for depProjectID, v := range info {
 		for _, dep := range v.Dependencies {
@@ -427,9 +529,12 @@ func (m *Modpack) getModInfoList() {
 	}
 
 	m.Mods = info
+	diagnostics = append(diagnostics, m.serverVersionDrift()...)
+	sortDiagnostics(diagnostics)
+	m.Diagnostics = diagnostics
 }
 
-func (m *Modpack) syncCurseKeyMap(shouldExist bool, projectID, fileID int, curseKeyMap map[int]int) {
+func (m *Modpack) syncCurseKeyMap(shouldExist bool, projectID, fileID int, required bool, curseKeyMap map[int]int) {
 	// Does the project exist in the manifest?
 	if key, ok := curseKeyMap[projectID]; ok {
 		if shouldExist {
@@ -439,6 +544,9 @@ func (m *Modpack) syncCurseKeyMap(shouldExist bool, projectID, fileID int, curse
 				fmt.Println("Updated curse id")
 				m.CurseManifest.Files[key].FileID = fileID
 			}
+			if m.CurseManifest.Files[key].Required != required {
+				m.CurseManifest.Files[key].Required = required
+			}
 		} else {
 			// Delete (from SliceTricks)
 			m.CurseManifest.Files = append(m.CurseManifest.Files[:key], m.CurseManifest.Files[key+1:]...)
@@ -456,7 +564,7 @@ func (m *Modpack) syncCurseKeyMap(shouldExist bool, projectID, fileID int, curse
 			ProjectID int  `json:"projectID"`
 			FileID    int  `json:"fileID"`
 			Required  bool `json:"required"`
-		}{projectID, fileID, true})
+		}{projectID, fileID, required})
 		fmt.Println("Added curse id")
 	}
 	// If !exists and !shouldExist, ignore
@@ -589,7 +697,7 @@ func (m *Modpack) updateModLists() error {
 	for projectID, v := range m.Mods {
 		if v.OnClient {
 			// Must be in curseKeyMap
-			m.syncCurseKeyMap(true, projectID, v.FileID, curseKeyMap)
+			m.syncCurseKeyMap(true, projectID, v.FileID, v.Required, curseKeyMap)
 			// Must not be in additionalFilesSlugMap
 			m.syncAdditionalFilesSlugMap(false, v.Slug, v.FileID, additionalFilesSlugMap)
 			if v.OnServer {
@@ -601,7 +709,7 @@ func (m *Modpack) updateModLists() error {
 			}
 		} else if v.OnServer {
 			// Must not be in curseKeyMap
-			m.syncCurseKeyMap(false, projectID, v.FileID, curseKeyMap)
+			m.syncCurseKeyMap(false, projectID, v.FileID, v.Required, curseKeyMap)
 			// Must not be in ignoreProjectKeyMap
 			m.syncIgnoreProjectKeyMap(false, projectID, ignoreProjectKeyMap)
 			// Must be in additionalFilesSlugMap
@@ -632,29 +740,38 @@ func (m *Modpack) updateModLists() error {
 	return nil
 }
 
-func (m *Modpack) saveConfigFiles() error {
+// marshalConfigFiles encodes the manifest.json and server-setup-config.yaml as they are saved
+func (m *Modpack) marshalConfigFiles() ([]byte, []byte, error) {
 	manifest, err := json.Marshal(&m.CurseManifest)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	var manifestBuffer bytes.Buffer
 	json.Indent(&manifestBuffer, manifest, "", "  ")
 
-	f, err := os.Create(filepath.Join(m.Folder, "manifest.json"))
+	config, err := yaml.Marshal(&m.ServerSetupConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifestBuffer.Bytes(), config, nil
+}
+
+func (m *Modpack) saveConfigFiles() error {
+	manifest, config, err := m.marshalConfigFiles()
 	if err != nil {
 		return err
 	}
-	_, err = manifestBuffer.WriteTo(f)
+
+	f, err := os.Create(filepath.Join(m.Folder, "manifest.json"))
 	if err != nil {
 		return err
 	}
-	err = f.Close()
+	_, err = f.Write(manifest)
 	if err != nil {
 		return err
 	}
-
-	config, err := yaml.Marshal(&m.ServerSetupConfig)
+	err = f.Close()
 	if err != nil {
 		return err
 	}
@@ -663,25 +780,170 @@ func (m *Modpack) saveConfigFiles() error {
 	if err != nil {
 		return err
 	}
+
+	m.Revision = packRevision(manifest, config)
+	m.diskManifest = manifest
+	m.diskConfig = config
 	return nil
 }
 
-func saveModpack(w http.ResponseWriter, newPack Modpack) {
-	modpackMutex.Lock()
-	defer modpackMutex.Unlock()
-	modpack = newPack
+// saveLock is read locked while modpack files are written. Shutdown takes the write lock,
+// so it waits for saves to finish and no more can start.
+var saveLock sync.RWMutex
+
+func saveModpack(w http.ResponseWriter, pack *openModpack, newPack Modpack) {
+	saveLock.RLock()
+	defer saveLock.RUnlock()
+	pack.mutex.Lock()
+	defer pack.mutex.Unlock()
+	oldPack := pack.modpack
+	// The folder of an open modpack can't be changed
+	newPack.Folder = pack.folder
+
+	// Saving a partial mod list would remove the mods that haven't loaded
+	if oldPack.LoadingMods {
+		writeError(w, errors.New("The mod list is still loading, wait for it to finish before saving"))
+		return
+	}
 
-	err := modpack.updateModLists()
+	// Don't overwrite changes made by someone else since this was loaded
+	if len(newPack.Revision) == 0 {
+		writeError(w, errors.New("No revision given, reload the modpack before saving"))
+		return
+	}
+	conflict, err := checkRevision(&oldPack, newPack.Revision)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
+	if conflict != nil {
+		writeConflict(w, conflict)
+		return
+	}
+
+	// Work on newPack, so the open modpack only changes once the files are written
+	modpack := &newPack
+	err = modpack.updateModLists()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	modpack.syncServerVersions()
+
+	// Check before writing anything, so nothing unrelated ends up in the commit
+	if modpack.Settings.GitCommit {
+		err = checkGitStaging(modpack.Folder)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	// Keep the state from before the first save, so it can be restored
+	if !hasSnapshots(modpack.Folder) {
+		_, err = recordSnapshot(oldPack.Folder, oldPack.modSides(), PackChanges{}, "State before editing")
+		if err != nil {
+			log.Print("Error recording edit history:")
+			log.Print(err)
+		}
+	}
 
 	err = modpack.saveConfigFiles()
 	if err != nil {
 		writeError(w, err)
 		return
 	}
+	pack.modpack = newPack
+	modpack = &pack.modpack
+
+	// The files are written from here, so errors include the new revision
+	err = modpack.savePackSettings()
+	if err != nil {
+		writeSaveError(w, fmt.Errorf("Modpack saved, but the editor settings weren't: %v", err), modpack.Revision)
+		return
+	}
 
-	json.NewEncoder(w).Encode(struct{}{})
+	changes := comparePacks(&oldPack, modpack)
+	// Saves that change nothing aren't worth undoing
+	if !changes.IsEmpty() {
+		_, err = recordSnapshot(modpack.Folder, modpack.modSides(), changes, changes.String())
+		if err != nil {
+			log.Print("Error recording edit history:")
+			log.Print(err)
+		}
+	}
+
+	if modpack.Settings.GitCommit {
+		err = commitConfigFiles(modpack.Folder, changes)
+		if err != nil {
+			writeSaveError(w, fmt.Errorf("Modpack saved, but not committed: %v", err), modpack.Revision)
+			return
+		}
+	}
+
+	// Suggest removing newly added client only mods from the server
+	addedIDs := make([]int, 0, len(changes.Added))
+	for _, v := range changes.Added {
+		addedIDs = append(addedIDs, v.ProjectID)
+	}
+	var suggestions []SideSuggestion
+	if len(addedIDs) > 0 {
+		suggestions = modpack.suggestSides(addedIDs, false)
+	}
+
+	// The server config may have changed when versions were synced
+	json.NewEncoder(w).Encode(struct {
+		Revision          string
+		SideSuggestions   []SideSuggestion
+		ServerSetupConfig ServerSetupConfig
+		VersionDrift      []Diagnostic
+	}{modpack.Revision, suggestions, modpack.ServerSetupConfig, modpack.serverVersionDrift()})
+}
+
+// writeSaveError reports an error that happened after the config files were saved,
+// with the revision of the saved files so the next save doesn't conflict
+func writeSaveError(w http.ResponseWriter, e error, revision string) {
+	w.WriteHeader(400)
+	json.NewEncoder(w).Encode(struct {
+		ErrorMessage string
+		Revision     string
+	}{e.Error(), revision})
+}
+
+// previewSave shows what saveModpack would change, without writing anything
+func previewSave(w http.ResponseWriter, pack *openModpack, newPack Modpack) {
+	// Copy what's needed, so slug lookups don't block the modpack
+	pack.mutex.RLock()
+	newPack.Folder = pack.folder
+	oldManifest, oldConfig := pack.modpack.diskManifest, pack.modpack.diskConfig
+	pack.mutex.RUnlock()
+
+	err := newPack.updateModLists()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	newPack.syncServerVersions()
+	newManifest, newConfig, err := newPack.marshalConfigFiles()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The summary and the diff both compare the files, so they agree
+	oldCurseManifest, oldServerSetupConfig, err := parseConfigFiles(oldManifest, oldConfig)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	changes := compareModSides(sidesFromConfigFiles(oldCurseManifest, oldServerSetupConfig), sidesFromConfigFiles(newPack.CurseManifest, newPack.ServerSetupConfig))
+	changes.Settings = compareSettings(oldCurseManifest, oldServerSetupConfig, newPack.CurseManifest, newPack.ServerSetupConfig)
+	diff := unifiedDiff("a/manifest.json", "b/manifest.json", string(oldManifest), string(newManifest)) +
+		unifiedDiff("a/server-setup-config.yaml", "b/server-setup-config.yaml", string(oldConfig), string(newConfig))
+
+	json.NewEncoder(w).Encode(struct {
+		Summary string
+		Changes PackChanges
+		Diff    string
+	}{changes.String(), changes, diff})
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModInfoUnmarshalRequired(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"missing", `{"Name": "JEI"}`, true},
+		{"true", `{"Name": "JEI", "Required": true}`, true},
+		{"false", `{"Name": "JEI", "Required": false}`, false},
+		{"null", `{"Name": "JEI", "Required": null}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mod ModInfo
+			err := json.Unmarshal([]byte(tt.data), &mod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mod.Required != tt.want || mod.Name != "JEI" {
+				t.Errorf("got Required %v and Name %q, want %v and JEI", mod.Required, mod.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestModInfoUnmarshalInModpack(t *testing.T) {
+	// Mods are posted by the editor as part of a modpack
+	var pack Modpack
+	err := json.Unmarshal([]byte(`{"Mods": {"1": {"FileID": 10}, "2": {"FileID": 20, "Required": false}}}`), &pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pack.Mods[1].Required || pack.Mods[1].FileID != 10 {
+		t.Errorf("mod sent without Required was decoded as %+v", pack.Mods[1])
+	}
+	if pack.Mods[2].Required {
+		t.Errorf("optional mod was decoded as required")
+	}
+
+	// Optional mods stay optional when they are sent back
+	encoded, err := json.Marshal(pack.Mods[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ModInfo
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Required {
+		t.Errorf("optional mod became required after encoding: %s", encoded)
+	}
+}
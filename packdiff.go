@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ModSide is the side configuration of a single mod, as stored in the edit history
+type ModSide struct {
+	Name     string
+	Slug     string
+	FileID   int
+	OnClient bool
+	OnServer bool
+	// Optional is stored instead of Required, so history from before it was recorded is required
+	Optional bool `json:",omitempty"`
+}
+
+// ModChange is a mod that differs between two versions of a modpack
+// Old is nil for added mods, New is nil for removed mods
+type ModChange struct {
+	ProjectID int
+	Name      string
+	Old       *ModSide
+	New       *ModSide
+}
+
+// SettingChange is a setting that differs between two versions of a modpack
+type SettingChange struct {
+	Setting string
+	Old     string
+	New     string
+}
+
+// PackChanges is a summary of the differences between two versions of a modpack
+type PackChanges struct {
+	Added           []ModChange
+	Removed         []ModChange
+	Updated         []ModChange
+	SidesChanged    []ModChange
+	RequiredChanged []ModChange
+	Settings        []SettingChange
+}
+
+func (m *Modpack) modSides() map[int]ModSide {
+	sides := make(map[int]ModSide, len(m.Mods))
+	for projectID, v := range m.Mods {
+		sides[projectID] = ModSide{
+			Name:     v.Name,
+			Slug:     v.Slug,
+			FileID:   v.FileID,
+			OnClient: v.OnClient,
+			OnServer: v.OnServer,
+			Optional: !v.Required,
+		}
+	}
+	return sides
+}
+
+// comparePacks summarises the changes from one version of a modpack to another
+func comparePacks(oldPack, newPack *Modpack) PackChanges {
+	changes := compareModSides(oldPack.modSides(), newPack.modSides())
+	changes.Settings = compareSettings(oldPack.CurseManifest, oldPack.ServerSetupConfig, newPack.CurseManifest, newPack.ServerSetupConfig)
+	return changes
+}
+
+func compareModSides(oldSides, newSides map[int]ModSide) PackChanges {
+	var changes PackChanges
+	for projectID, newSide := range newSides {
+		newSide := newSide
+		oldSide, ok := oldSides[projectID]
+		if !ok {
+			changes.Added = append(changes.Added, ModChange{projectID, modDisplayName(projectID, newSide), nil, &newSide})
+			continue
+		}
+		change := ModChange{projectID, modDisplayName(projectID, newSide), &oldSide, &newSide}
+		if oldSide.FileID != newSide.FileID {
+			changes.Updated = append(changes.Updated, change)
+		}
+		if oldSide.OnClient != newSide.OnClient || oldSide.OnServer != newSide.OnServer {
+			changes.SidesChanged = append(changes.SidesChanged, change)
+		}
+		if oldSide.Optional != newSide.Optional {
+			changes.RequiredChanged = append(changes.RequiredChanged, change)
+		}
+	}
+	for projectID, oldSide := range oldSides {
+		oldSide := oldSide
+		if _, ok := newSides[projectID]; !ok {
+			changes.Removed = append(changes.Removed, ModChange{projectID, modDisplayName(projectID, oldSide), &oldSide, nil})
+		}
+	}
+
+	// Maps are unordered, so sort by name to give stable output
+	for _, list := range [][]ModChange{changes.Added, changes.Removed, changes.Updated, changes.SidesChanged, changes.RequiredChanged} {
+		sort.Slice(list, func(i, j int) bool {
+			return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+		})
+	}
+	return changes
+}
+
+func modDisplayName(projectID int, side ModSide) string {
+	if len(side.Name) > 0 {
+		return side.Name
+	}
+	return fmt.Sprintf("Project %d", projectID)
+}
+
+// compareSettings lists the settings that differ between two versions of the config files,
+// ignoring the parts that are generated from the mod list
+func compareSettings(oldManifest CurseManifest, oldConfig ServerSetupConfig, newManifest CurseManifest, newConfig ServerSetupConfig) []SettingChange {
+	oldSettings := flattenPackSettings(oldManifest, oldConfig)
+	newSettings := flattenPackSettings(newManifest, newConfig)
+
+	var changes []SettingChange
+	for name, newValue := range newSettings {
+		if oldValue := oldSettings[name]; oldValue != newValue {
+			changes = append(changes, SettingChange{name, oldValue, newValue})
+		}
+	}
+	for name, oldValue := range oldSettings {
+		if _, ok := newSettings[name]; !ok {
+			changes = append(changes, SettingChange{name, oldValue, ""})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Setting < changes[j].Setting
+	})
+	return changes
+}
+
+func flattenPackSettings(manifest CurseManifest, config ServerSetupConfig) map[string]string {
+	// These are edited through the mod list
+	manifest.Files = nil
+	config.Install.FormatSpecific.IgnoreProject = nil
+	config.Install.AdditionalFiles = nil
+
+	settings := make(map[string]string)
+	encoded, err := json.Marshal(manifest)
+	if err == nil {
+		var decoded interface{}
+		if json.Unmarshal(encoded, &decoded) == nil {
+			flattenSetting("manifest", decoded, settings)
+		}
+	}
+	// The server config is encoded as YAML, so settings are named with the keys in the file
+	encoded, err = yaml.Marshal(config)
+	if err == nil {
+		var decoded interface{}
+		if yaml.Unmarshal(encoded, &decoded) == nil {
+			flattenSetting("serverConfig", stringKeys(decoded), settings)
+		}
+	}
+	return settings
+}
+
+// stringKeys converts the maps decoded from YAML to maps with string keys, as decoded from JSON
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted[fmt.Sprint(key)] = stringKeys(child)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, child := range v {
+			converted[i] = stringKeys(child)
+		}
+		return converted
+	default:
+		return v
+	}
+}
+
+func flattenSetting(name string, value interface{}, settings map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenSetting(name+"."+key, child, settings)
+		}
+	case string:
+		settings[name] = v
+	default:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			settings[name] = string(encoded)
+		}
+	}
+}
+
+// IsEmpty returns true if nothing changed
+func (c PackChanges) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0 && len(c.SidesChanged) == 0 && len(c.RequiredChanged) == 0 && len(c.Settings) == 0
+}
+
+// String returns a short, one line summary of the changes
+func (c PackChanges) String() string {
+	if c.IsEmpty() {
+		return "No changes"
+	}
+
+	var parts []string
+	modNames := func(label string, list []ModChange) {
+		if len(list) == 0 {
+			return
+		}
+		names := make([]string, len(list))
+		for i, v := range list {
+			names[i] = v.Name
+		}
+		parts = append(parts, label+": "+strings.Join(names, ", "))
+	}
+	modNames("Added", c.Added)
+	modNames("Removed", c.Removed)
+	modNames("Updated", c.Updated)
+	modNames("Sides changed", c.SidesChanged)
+	modNames("Required changed", c.RequiredChanged)
+	if len(c.Settings) > 0 {
+		names := make([]string, len(c.Settings))
+		for i, v := range c.Settings {
+			names[i] = v.Setting
+		}
+		parts = append(parts, "Settings changed: "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareModSides(t *testing.T) {
+	jei := ModSide{Name: "JEI", FileID: 1, OnClient: true, OnServer: true}
+	tests := []struct {
+		name     string
+		oldSides map[int]ModSide
+		newSides map[int]ModSide
+		want     string
+	}{
+		{"nothing changed", map[int]ModSide{1: jei}, map[int]ModSide{1: jei}, "No changes"},
+		{"added", nil, map[int]ModSide{1: jei}, "Added: JEI"},
+		{"removed", map[int]ModSide{1: jei}, nil, "Removed: JEI"},
+		{"updated", map[int]ModSide{1: jei}, map[int]ModSide{1: {Name: "JEI", FileID: 2, OnClient: true, OnServer: true}}, "Updated: JEI"},
+		{"sides changed", map[int]ModSide{1: jei}, map[int]ModSide{1: {Name: "JEI", FileID: 1, OnClient: true}}, "Sides changed: JEI"},
+		{"required changed", map[int]ModSide{1: jei}, map[int]ModSide{1: {Name: "JEI", FileID: 1, OnClient: true, OnServer: true, Optional: true}}, "Required changed: JEI"},
+		{"updated and moved", map[int]ModSide{1: jei}, map[int]ModSide{1: {Name: "JEI", FileID: 2, OnServer: true}}, "Updated: JEI; Sides changed: JEI"},
+		{"unnamed", nil, map[int]ModSide{5: {FileID: 1}}, "Added: Project 5"},
+		{"sorted by name", nil, map[int]ModSide{1: {Name: "b"}, 2: {Name: "A"}, 3: {Name: "c"}}, "Added: A, b, c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareModSides(tt.oldSides, tt.newSides).String()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *Modpack)
+		want   []string
+	}{
+		{"nothing changed", func(m *Modpack) {}, nil},
+		{"manifest", func(m *Modpack) { m.CurseManifest.Name = "New name" }, []string{"manifest.name"}},
+		{"server config uses yaml keys", func(m *Modpack) {
+			m.ServerSetupConfig.Install.McVersion = "1.12.1"
+			m.ServerSetupConfig.Launch.MaxRAM = "6G"
+		}, []string{"serverConfig.install.mcVersion", "serverConfig.launch.maxRam"}},
+		{"lists", func(m *Modpack) {
+			m.ServerSetupConfig.Install.IgnoreFiles = append(m.ServerSetupConfig.Install.IgnoreFiles, "logs/**")
+		}, []string{"serverConfig.install.ignoreFiles"}},
+		{"mod lists are left out", func(m *Modpack) {
+			m.CurseManifest.Files = nil
+			m.ServerSetupConfig.Install.FormatSpecific.IgnoreProject = []int{1}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldPack := testModpack(t, testForgeManifest, testForgeConfig)
+			newPack := testModpack(t, testForgeManifest, testForgeConfig)
+			tt.change(&newPack)
+
+			var got []string
+			for _, v := range compareSettings(oldPack.CurseManifest, oldPack.ServerSetupConfig, newPack.CurseManifest, newPack.ServerSetupConfig) {
+				got = append(got, v.Setting)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
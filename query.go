@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -113,21 +114,43 @@ type FileData struct {
 	GameVersion        []string `json:"gameVersion"`
 	Alternate          bool     `json:"alternate"`
 	Available          bool     `json:"available"`
+	// LastQueried is zero for files cached before it was recorded
+	LastQueried time.Time
+}
+
+// offlineMode stops all requests to the API, so only cached data is used
+var offlineMode bool
+
+// addonMaxAge is how long a cached project is used before it is fetched again
+const addonMaxAge = 48 * time.Hour
+
+// isStale returns true if the project should have been fetched again, but was used anyway in offline mode
+func (data AddonData) isStale() bool {
+	return offlineMode && time.Since(data.LastQueried) >= addonMaxAge
 }
 
 func requestAddonData(addonID int) (AddonData, error) {
-	// Use a cached mod, if it's available and up to date
+	// Use a cached mod, if it's available and up to date (or can't be updated)
 	mainCache.cachedModsMutex.RLock()
 	if mainCache.CachedMods[addonID].Available {
-		if time.Since(mainCache.CachedMods[addonID].LastQueried) < 48*time.Hour {
+		if offlineMode || time.Since(mainCache.CachedMods[addonID].LastQueried) < addonMaxAge {
 			defer mainCache.cachedModsMutex.RUnlock()
+			markModUsed(addonID)
 			return mainCache.CachedMods[addonID], nil
 		}
 	}
 	mainCache.cachedModsMutex.RUnlock()
 
+	return fetchAddonData(addonID)
+}
+
+// fetchAddonData requests a project from the API and caches it, even if the cached copy is up to date
+func fetchAddonData(addonID int) (AddonData, error) {
 	// Uses the curse.nikky.moe api
 	var data AddonData
+	if offlineMode {
+		return data, fmt.Errorf("Project %d isn't in the cache, and can't be downloaded in offline mode", addonID)
+	}
 	client := &http.Client{}
 
 	req, err := http.NewRequest("GET", fmt.Sprintf("https://curse.nikky.moe/api/addon/%d", addonID), nil)
@@ -149,19 +172,26 @@ func requestAddonData(addonID int) (AddonData, error) {
 	}
 
 	// Add files to file cache
+	data.LastQueried = time.Now()
 	mainCache.cachedFilesMutex.Lock()
 	for _, v := range data.LatestFiles {
 		if _, ok := mainCache.CachedFiles[v.ID]; !ok {
+			v.LastQueried = data.LastQueried
 			mainCache.CachedFiles[v.ID] = v
 		}
 	}
 	mainCache.cachedFilesMutex.Unlock()
 
 	// Add to cache
-	data.LastQueried = time.Now()
 	mainCache.cachedModsMutex.Lock()
-	mainCache.CachedMods[addonID] = data
+	if trimCachedMods {
+		mainCache.CachedMods[addonID] = data.trimmed()
+	} else {
+		mainCache.CachedMods[addonID] = data
+	}
 	mainCache.cachedModsMutex.Unlock()
+	markModUsed(addonID)
+	markCacheChanged()
 	return data, nil
 }
 
@@ -170,12 +200,21 @@ func requestFileData(addonID, fileID int) (FileData, error) {
 	mainCache.cachedFilesMutex.RLock()
 	if mainCache.CachedFiles[fileID].Available {
 		defer mainCache.cachedFilesMutex.RUnlock()
+		markFileUsed(fileID)
 		return mainCache.CachedFiles[fileID], nil
 	}
 	mainCache.cachedFilesMutex.RUnlock()
 
+	return fetchFileData(addonID, fileID)
+}
+
+// fetchFileData requests a file from the API and caches it, even if the cached copy is available
+func fetchFileData(addonID, fileID int) (FileData, error) {
 	// Uses the curse.nikky.moe api
 	var data FileData
+	if offlineMode {
+		return data, fmt.Errorf("File %d of project %d isn't in the cache, and can't be downloaded in offline mode", fileID, addonID)
+	}
 	client := &http.Client{}
 
 	req, err := http.NewRequest("GET", fmt.Sprintf("https://curse.nikky.moe/api/addon/%d/file/%d", addonID, fileID), nil)
@@ -197,9 +236,12 @@ func requestFileData(addonID, fileID int) (FileData, error) {
 	}
 
 	// Add to cache
+	data.LastQueried = time.Now()
 	mainCache.cachedFilesMutex.Lock()
 	mainCache.CachedFiles[fileID] = data
 	mainCache.cachedFilesMutex.Unlock()
+	markFileUsed(fileID)
+	markCacheChanged()
 	return data, nil
 }
 
@@ -233,6 +275,9 @@ func requestAddonDataFromSlug(slug string) (AddonData, error) {
 	mainCache.cachedSlugIDsMutex.RUnlock()
 
 	var data AddonData
+	if offlineMode {
+		return data, fmt.Errorf("The ID of %s isn't in the cache, and can't be downloaded in offline mode", slug)
+	}
 
 	request := AddonSlugRequest{
 		Query: `
@@ -290,6 +335,7 @@ func requestAddonDataFromSlug(slug string) (AddonData, error) {
 	mainCache.cachedSlugIDsMutex.Lock()
 	mainCache.CachedSlugIDs[slug] = response.Data.Addons[0].ID
 	mainCache.cachedSlugIDsMutex.Unlock()
+	markCacheChanged()
 	return data, err
 }
 
@@ -303,6 +349,9 @@ func requestFileDataFromSlug(slug string, fileID int) (FileData, error) {
 	mainCache.cachedSlugIDsMutex.RUnlock()
 
 	var data FileData
+	if offlineMode {
+		return data, fmt.Errorf("The ID of %s isn't in the cache, and can't be downloaded in offline mode", slug)
+	}
 
 	request := AddonSlugRequest{
 		Query: `
@@ -360,6 +409,7 @@ func requestFileDataFromSlug(slug string, fileID int) (FileData, error) {
 	mainCache.cachedSlugIDsMutex.Lock()
 	mainCache.CachedSlugIDs[slug] = response.Data.Addons[0].ID
 	mainCache.cachedSlugIDsMutex.Unlock()
+	markCacheChanged()
 	return data, err
 }
 
@@ -373,8 +423,15 @@ type ModpackEditorCache struct {
 	cachedSlugIDsMutex sync.RWMutex
 	CachedFiles        map[int]FileData
 	cachedFilesMutex   sync.RWMutex
-	LastOpenedModpack  string
-	CacheVersion       int
+	// LastOpenedModpack is only read when migrating version 3 caches, OpenModpacks replaced it
+	LastOpenedModpack string
+	OpenModpacks      []string
+	openModpacksMutex sync.RWMutex
+	// ModLastUsed and FileLastUsed store when entries were last used, to evict the least recently used
+	ModLastUsed   map[int]time.Time
+	FileLastUsed  map[int]time.Time
+	lastUsedMutex sync.Mutex
+	CacheVersion  int
 }
 
 // NewModpackEditorCache initialises the maps in ModpackEditorCache
@@ -383,21 +440,25 @@ func NewModpackEditorCache() *ModpackEditorCache {
 		CachedMods:    make(map[int]AddonData),
 		CachedSlugIDs: make(map[string]int),
 		CachedFiles:   make(map[int]FileData),
+		ModLastUsed:   make(map[int]time.Time),
+		FileLastUsed:  make(map[int]time.Time),
 		CacheVersion:  CurrentCacheVersion,
 	}
 	return &cache
 }
 
-// CurrentCacheVersion is the version of the editor cache file being used. Older caches are ignored.
-const CurrentCacheVersion = 3
+// CurrentCacheVersion is the version of the editor cache file being used. Older caches are migrated.
+const CurrentCacheVersion = 4
 
-func loadEditorCache() *Modpack {
+func loadEditorCache() {
 	if disableCacheStore {
 		mainCache = *NewModpackEditorCache()
-		return nil
+		return
 	}
 
-	file, err := os.Open("modpackEditorCache.bin")
+	moveOldEditorCache()
+
+	file, err := os.Open(editorCachePath)
 	if err == nil {
 		defer file.Close()
 		var newModpackEditorCache ModpackEditorCache
@@ -406,29 +467,30 @@ func loadEditorCache() *Modpack {
 			log.Print("Error loading from cache:")
 			log.Print(err)
 			mainCache = *NewModpackEditorCache()
-			return nil
+			return
 		}
 		err = gob.NewDecoder(zr).Decode(&newModpackEditorCache)
 		if err != nil && err != io.EOF {
 			log.Print("Error loading from cache:")
 			log.Print(err)
 			mainCache = *NewModpackEditorCache()
-			return nil
+			return
 		}
 
-		if newModpackEditorCache.CacheVersion < CurrentCacheVersion {
-			log.Print("Cache is too old, discarding")
+		if !migrateEditorCache(&newModpackEditorCache) {
 			mainCache = *NewModpackEditorCache()
-			return nil
+			return
 		}
 
 		// Can't assign directly as it contains mutexes
 		mainCache = ModpackEditorCache{
-			CachedMods:        newModpackEditorCache.CachedMods,
-			CachedSlugIDs:     newModpackEditorCache.CachedSlugIDs,
-			CachedFiles:       newModpackEditorCache.CachedFiles,
-			LastOpenedModpack: newModpackEditorCache.LastOpenedModpack,
-			CacheVersion:      CurrentCacheVersion,
+			CachedMods:    newModpackEditorCache.CachedMods,
+			CachedSlugIDs: newModpackEditorCache.CachedSlugIDs,
+			CachedFiles:   newModpackEditorCache.CachedFiles,
+			OpenModpacks:  newModpackEditorCache.OpenModpacks,
+			ModLastUsed:   newModpackEditorCache.ModLastUsed,
+			FileLastUsed:  newModpackEditorCache.FileLastUsed,
+			CacheVersion:  CurrentCacheVersion,
 		}
 		if newModpackEditorCache.CachedMods == nil {
 			mainCache.CachedMods = make(map[int]AddonData)
@@ -439,37 +501,45 @@ func loadEditorCache() *Modpack {
 		if newModpackEditorCache.CachedFiles == nil {
 			mainCache.CachedFiles = make(map[int]FileData)
 		}
-
-		// Load existing modpack
-		if len(mainCache.LastOpenedModpack) > 0 {
-			folderAbsolute, err := filepath.Abs(mainCache.LastOpenedModpack)
-			if err != nil {
-				log.Print("Error loading modpack from cached folder:")
-				log.Print(err)
-				return nil
-			}
-
-			newModpack := &Modpack{Folder: folderAbsolute}
-			err = newModpack.loadConfigFiles()
-			if err != nil {
-				log.Print("Error loading modpack from cached folder:")
-				log.Print(err)
-				// Clear value
-				newModpack = nil
-			}
-			// Update mod list
-			newModpack.getModInfoList()
-
-			return newModpack
+		if newModpackEditorCache.ModLastUsed == nil {
+			mainCache.ModLastUsed = make(map[int]time.Time)
+		}
+		if newModpackEditorCache.FileLastUsed == nil {
+			mainCache.FileLastUsed = make(map[int]time.Time)
+		}
+		if trimCachedMods {
+			trimEditorCache()
 		}
 	} else if os.IsNotExist(err) {
 		mainCache = *NewModpackEditorCache()
 	} else {
 		log.Print("Error loading from cache:")
 		log.Print(err)
+		mainCache = *NewModpackEditorCache()
 	}
+}
 
-	return nil
+// loadCachedModpack opens a modpack folder stored in the cache
+func loadCachedModpack(folder string) *Modpack {
+	folderAbsolute, err := filepath.Abs(folder)
+	if err != nil {
+		log.Print("Error loading modpack from cached folder:")
+		log.Print(err)
+		return nil
+	}
+
+	newModpack := &Modpack{Folder: folderAbsolute}
+	err = newModpack.loadConfigFiles()
+	if err != nil {
+		log.Print("Error loading modpack from cached folder:")
+		log.Print(err)
+		return nil
+	}
+	// The mod list is loaded in the background once the modpack is open
+	newModpack.Mods = make(map[int]ModInfo)
+	newModpack.LoadingMods = true
+
+	return newModpack
 }
 
 func writeEditorCache() {
@@ -477,28 +547,68 @@ func writeEditorCache() {
 		return
 	}
 
-	// Ensure mutexes are correct for gob to encode the maps
+	// Copy the maps, so they aren't locked while the cache is encoded
+	cache := ModpackEditorCache{CacheVersion: CurrentCacheVersion}
 	mainCache.cachedModsMutex.RLock()
-	defer mainCache.cachedModsMutex.RUnlock()
+	cache.CachedMods = make(map[int]AddonData, len(mainCache.CachedMods))
+	for k, v := range mainCache.CachedMods {
+		cache.CachedMods[k] = v
+	}
+	mainCache.cachedModsMutex.RUnlock()
 	mainCache.cachedSlugIDsMutex.RLock()
-	defer mainCache.cachedSlugIDsMutex.RUnlock()
+	cache.CachedSlugIDs = make(map[string]int, len(mainCache.CachedSlugIDs))
+	for k, v := range mainCache.CachedSlugIDs {
+		cache.CachedSlugIDs[k] = v
+	}
+	mainCache.cachedSlugIDsMutex.RUnlock()
 	mainCache.cachedFilesMutex.RLock()
-	defer mainCache.cachedFilesMutex.RUnlock()
-
-	// Update lastOpenedModpack
-	mainCache.LastOpenedModpack = modpack.Folder
-
-	file, err := os.Create("modpackEditorCache.bin")
+	cache.CachedFiles = make(map[int]FileData, len(mainCache.CachedFiles))
+	for k, v := range mainCache.CachedFiles {
+		cache.CachedFiles[k] = v
+	}
+	mainCache.cachedFilesMutex.RUnlock()
+	mainCache.openModpacksMutex.RLock()
+	cache.OpenModpacks = mainCache.OpenModpacks
+	mainCache.openModpacksMutex.RUnlock()
+	mainCache.lastUsedMutex.Lock()
+	cache.ModLastUsed = make(map[int]time.Time, len(mainCache.ModLastUsed))
+	for k, v := range mainCache.ModLastUsed {
+		cache.ModLastUsed[k] = v
+	}
+	cache.FileLastUsed = make(map[int]time.Time, len(mainCache.FileLastUsed))
+	for k, v := range mainCache.FileLastUsed {
+		cache.FileLastUsed[k] = v
+	}
+	mainCache.lastUsedMutex.Unlock()
+
+	// Write to a temporary file first, so the cache is never left half written
+	cacheFolder := filepath.Dir(editorCachePath)
+	err := os.MkdirAll(cacheFolder, 0755)
+	if err != nil {
+		log.Print("Error writing to cache:")
+		log.Print(err)
+		return
+	}
+	file, err := ioutil.TempFile(cacheFolder, "modpackEditorCache-*.tmp")
 	if err != nil {
 		log.Print("Error writing to cache:")
 		log.Print(err)
 		return
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	zw := gzip.NewWriter(file)
-	defer zw.Close()
-	err = gob.NewEncoder(zw).Encode(&mainCache)
+	err = gob.NewEncoder(zw).Encode(&cache)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), editorCachePath)
+	}
 	if err != nil {
 		log.Print("Error writing to cache:")
 		log.Print(err)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// SaveConflict describes the changes made to a modpack's files since the revision a save was based on
+type SaveConflict struct {
+	Revision string
+	Summary  string
+	Changes  PackChanges
+	Diff     string
+}
+
+// packRevision identifies the contents of a modpack's config files
+func packRevision(manifest, config []byte) string {
+	hash := sha256.New()
+	hash.Write(manifest)
+	// Separate the files, so moving bytes between them changes the hash
+	hash.Write([]byte{0})
+	hash.Write(config)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// findRevisionFiles returns the config files of a revision, if it is the revision of
+// the loaded modpack or is in the edit history
+func findRevisionFiles(m *Modpack, revision string) ([]byte, []byte, bool) {
+	if m.Revision == revision && m.diskManifest != nil {
+		return m.diskManifest, m.diskConfig, true
+	}
+
+	snapshots, err := listSnapshots(m.Folder)
+	if err != nil {
+		return nil, nil, false
+	}
+	for _, v := range snapshots {
+		if v.Revision != revision {
+			continue
+		}
+		contents, err := readSnapshot(m.Folder, v.ID)
+		if err != nil {
+			return nil, nil, false
+		}
+		return contents.Manifest, contents.Config, true
+	}
+	return nil, nil, false
+}
+
+// checkRevision compares a revision to the files on disk, and describes what changed if they differ
+func checkRevision(m *Modpack, revision string) (*SaveConflict, error) {
+	manifest, config, err := readConfigFiles(m.Folder)
+	if err != nil {
+		return nil, err
+	}
+	current := packRevision(manifest, config)
+	if current == revision {
+		return nil, nil
+	}
+
+	conflict := &SaveConflict{Revision: current}
+	oldManifest, oldConfig, ok := findRevisionFiles(m, revision)
+	if !ok {
+		conflict.Summary = "The files were changed, but the revision being saved is unknown"
+		return conflict, nil
+	}
+
+	oldCurseManifest, oldServerSetupConfig, err := parseConfigFiles(oldManifest, oldConfig)
+	if err != nil {
+		return nil, err
+	}
+	newCurseManifest, newServerSetupConfig, err := parseConfigFiles(manifest, config)
+	if err != nil {
+		return nil, err
+	}
+	conflict.Changes = compareModSides(sidesFromConfigFiles(oldCurseManifest, oldServerSetupConfig), sidesFromConfigFiles(newCurseManifest, newServerSetupConfig))
+	conflict.Changes.Settings = compareSettings(oldCurseManifest, oldServerSetupConfig, newCurseManifest, newServerSetupConfig)
+	conflict.Summary = conflict.Changes.String()
+	conflict.Diff = unifiedDiff("a/manifest.json", "b/manifest.json", string(oldManifest), string(manifest)) +
+		unifiedDiff("a/server-setup-config.yaml", "b/server-setup-config.yaml", string(oldConfig), string(config))
+	return conflict, nil
+}
+
+// sidesFromConfigFiles works out mod sides from the config files alone, using cached names and slugs.
+// Server-only mods with uncached slugs are left out.
+func sidesFromConfigFiles(manifest CurseManifest, config ServerSetupConfig) map[int]ModSide {
+	sides := make(map[int]ModSide)
+
+	ignored := make(map[int]bool)
+	for _, v := range config.Install.FormatSpecific.IgnoreProject {
+		ignored[v] = true
+	}
+
+	mainCache.cachedModsMutex.RLock()
+	defer mainCache.cachedModsMutex.RUnlock()
+
+	for _, v := range manifest.Files {
+		sides[v.ProjectID] = ModSide{
+			Name:     mainCache.CachedMods[v.ProjectID].Name,
+			Slug:     mainCache.CachedMods[v.ProjectID].Slug,
+			FileID:   v.FileID,
+			OnClient: true,
+			OnServer: !ignored[v.ProjectID],
+			Optional: !v.Required,
+		}
+	}
+
+	re := regexp.MustCompile("https://minecraft.curseforge.com/projects/([\\w\\-]+)/files/(\\d+)/")
+	mainCache.cachedSlugIDsMutex.RLock()
+	defer mainCache.cachedSlugIDsMutex.RUnlock()
+	for _, v := range config.Install.AdditionalFiles {
+		matches := re.FindStringSubmatch(v.URL)
+		if len(matches) < 3 {
+			continue
+		}
+		projectID, ok := mainCache.CachedSlugIDs[matches[1]]
+		if !ok {
+			continue
+		}
+		fileID, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		sides[projectID] = ModSide{
+			Name:     mainCache.CachedMods[projectID].Name,
+			Slug:     matches[1],
+			FileID:   fileID,
+			OnServer: true,
+		}
+	}
+	return sides
+}
+
+func writeConflict(w http.ResponseWriter, conflict *SaveConflict) {
+	w.WriteHeader(409)
+	json.NewEncoder(w).Encode(struct {
+		ErrorMessage string
+		Conflict     *SaveConflict
+	}{"The modpack was changed since it was loaded, reload it before saving", conflict})
+}
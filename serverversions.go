@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fabricInstallerURL is the Fabric installer ServerStarter runs to install Fabric servers
+const fabricInstallerURL = "https://maven.fabricmc.net/net/fabricmc/fabric-installer/0.11.2/fabric-installer-0.11.2.jar"
+
+// fabricInstallerArguments installs a Fabric server, ServerStarter replaces the placeholders
+var fabricInstallerArguments = []string{"server", "-mcversion", "{{@mcversion@}}", "-loader", "{{@loaderversion@}}", "-downloadMinecraft"}
+
+// forgeLoaderInstallerURL is the Forge installer ServerStarter spec version 2 runs, with the same placeholders
+const forgeLoaderInstallerURL = forgeMavenURL + "{{@mcversion@}}-{{@loaderversion@}}/forge-{{@mcversion@}}-{{@loaderversion@}}-installer.jar"
+
+// forgeInstallerArguments installs a Forge server
+var forgeInstallerArguments = []string{"--installServer"}
+
+// isFabricInstaller returns true if an installer URL is for Fabric
+func isFabricInstaller(installerURL string) bool {
+	return strings.Contains(installerURL, "fabric-installer")
+}
+
+// parseModLoaderID splits a manifest modloader ID such as forge-14.23.4.2715 into the loader and its version
+func parseModLoaderID(id string) (loader, version string) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// primaryModLoader returns the loader and version of the primary modloader in the manifest
+func (m *Modpack) primaryModLoader() (loader, version string, ok bool) {
+	for _, v := range m.CurseManifest.Minecraft.ModLoaders {
+		if v.Primary {
+			loader, version = parseModLoaderID(v.ID)
+			return loader, version, true
+		}
+	}
+	return "", "", false
+}
+
+// usesLoaderSettings returns true if ServerStarter reads the modloader settings instead of the Forge ones
+func (c *ServerSetupConfig) usesLoaderSettings() bool {
+	return c.Specver >= 2
+}
+
+// syncServerVersions sets the versions ServerStarter installs from the manifest,
+// unless they are set manually for this modpack
+func (m *Modpack) syncServerVersions() {
+	if m.Settings.OverrideServerVersions {
+		return
+	}
+	install := &m.ServerSetupConfig.Install
+	if len(m.CurseManifest.Minecraft.Version) > 0 {
+		install.McVersion = m.CurseManifest.Minecraft.Version
+	}
+
+	loader, version, ok := m.primaryModLoader()
+	if !ok || len(version) == 0 {
+		return
+	}
+	switch loader {
+	case "forge":
+		if m.ServerSetupConfig.usesLoaderSettings() {
+			install.LoaderVersion = version
+			// Replace the Fabric installer when switching from Fabric, but keep a custom Forge installer
+			if len(install.InstallerURL) == 0 || isFabricInstaller(install.InstallerURL) {
+				install.InstallerURL = forgeLoaderInstallerURL
+				install.InstallerArguments = forgeInstallerArguments
+			}
+		} else {
+			install.ForgeVersion = version
+		}
+	case "fabric":
+		// Only ServerStarter spec version 2 can install Fabric
+		if !m.ServerSetupConfig.usesLoaderSettings() {
+			m.ServerSetupConfig.Specver = 2
+		}
+		install.LoaderVersion = version
+		installLoader := true
+		install.InstallLoader = &installLoader
+		// Keep a custom Fabric installer
+		if !isFabricInstaller(install.InstallerURL) {
+			install.InstallerURL = fabricInstallerURL
+			install.InstallerArguments = fabricInstallerArguments
+		}
+		install.InstallForge = false
+		install.ForgeVersion = ""
+		install.ForgeInstallerURL = ""
+	}
+}
+
+// serverVersionDrift reports server install versions that don't match the manifest.
+// Nothing is reported if they are set manually for this modpack.
+func (m *Modpack) serverVersionDrift() []Diagnostic {
+	if m.Settings.OverrideServerVersions {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	install := m.ServerSetupConfig.Install
+	manifestVersion := m.CurseManifest.Minecraft.Version
+	// A missing manifest version is reported by lintMinecraftVersion
+	if len(manifestVersion) > 0 && manifestVersion != install.McVersion {
+		diagnostics = append(diagnostics, Diagnostic{
+			Code:     LintMcVersionMismatch,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("The manifest uses Minecraft %s, but the server installs %q. Save to update the server config.", manifestVersion, install.McVersion),
+		})
+	}
+
+	loader, version, ok := m.primaryModLoader()
+	if !ok {
+		return diagnostics
+	}
+	switch {
+	case loader == "fabric" && !m.ServerSetupConfig.usesLoaderSettings():
+		diagnostics = append(diagnostics, Diagnostic{
+			Code:     LintLoaderVersionMismatch,
+			Severity: SeverityWarning,
+			Message:  "The manifest uses Fabric, but the server config is for Forge. Save to update the server config.",
+		})
+	case (loader == "fabric" || loader == "forge") && m.ServerSetupConfig.usesLoaderSettings():
+		if install.LoaderVersion != version {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     LintLoaderVersionMismatch,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("The manifest uses %s %s, but the server installs %q. Save to update the server config.", modLoaderName(loader), version, install.LoaderVersion),
+			})
+		}
+		// An empty installer URL is only filled in for Forge, so it isn't reported
+		if (loader == "fabric" && !isFabricInstaller(install.InstallerURL)) || (loader == "forge" && isFabricInstaller(install.InstallerURL)) {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     LintLoaderVersionMismatch,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("The manifest uses %s, but the server installer is %q. Save to update the server config.", modLoaderName(loader), install.InstallerURL),
+			})
+		}
+	case loader == "forge":
+		// An empty forgeVersion makes ServerStarter use the version in the manifest
+		if len(install.ForgeVersion) > 0 && install.ForgeVersion != version {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     LintForgeVersionMismatch,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("The manifest uses Forge %s, but the server installs %s. Save to update the server config.", version, install.ForgeVersion),
+			})
+		}
+	}
+	return diagnostics
+}
+
+// modLoaderName returns the display name of a loader in a modloader ID
+func modLoaderName(loader string) string {
+	switch loader {
+	case "forge":
+		return "Forge"
+	case "fabric":
+		return "Fabric"
+	default:
+		return loader
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseModLoaderID(t *testing.T) {
+	tests := []struct {
+		id, loader, version string
+	}{
+		{"forge-14.23.5.2854", "forge", "14.23.5.2854"},
+		{"fabric-0.11.6", "fabric", "0.11.6"},
+		{"forge-1.16.5-36.2.0", "forge", "1.16.5-36.2.0"},
+		{"forge", "forge", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		loader, version := parseModLoaderID(tt.id)
+		if loader != tt.loader || version != tt.version {
+			t.Errorf("parseModLoaderID(%q) = %q, %q, want %q, %q", tt.id, loader, version, tt.loader, tt.version)
+		}
+	}
+}
+
+// testLoaderManifest returns a manifest for a Minecraft version and primary modloader
+func testLoaderManifest(mcVersion, loaderID string) string {
+	return fmt.Sprintf(`{"minecraft": {"version": %q, "modLoaders": [{"id": %q, "primary": true}]}}`, mcVersion, loaderID)
+}
+
+const testFabricConfig = `
+_specver: 2
+install:
+  mcVersion: 1.16.5
+  loaderVersion: 0.11.6
+  installerUrl: ` + fabricInstallerURL + `
+  installerArguments: [server]
+  installLoader: true
+`
+
+func TestSyncServerVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		config   string
+		override bool
+		check    func(t *testing.T, c ServerSetupConfig)
+	}{
+		{"Forge on spec 1", testLoaderManifest("1.12.2", "forge-14.23.5.2854"), "_specver: 1\n", false, func(t *testing.T, c ServerSetupConfig) {
+			if c.Specver != 1 || c.Install.McVersion != "1.12.2" || c.Install.ForgeVersion != "14.23.5.2854" {
+				t.Errorf("got spec %d, Minecraft %q, Forge %q", c.Specver, c.Install.McVersion, c.Install.ForgeVersion)
+			}
+			if c.Install.InstallLoader != nil || len(c.Install.LoaderVersion) > 0 || len(c.Install.InstallerURL) > 0 {
+				t.Errorf("spec 2 settings were set: %+v", c.Install)
+			}
+		}},
+		{"Fabric switches spec 1 to 2", testLoaderManifest("1.16.5", "fabric-0.11.6"), "_specver: 1\ninstall:\n  forgeVersion: 36.2.0\n  installForge: true\n", false, func(t *testing.T, c ServerSetupConfig) {
+			if c.Specver != 2 || c.Install.LoaderVersion != "0.11.6" || c.Install.InstallerURL != fabricInstallerURL {
+				t.Errorf("got spec %d, loader %q, installer %q", c.Specver, c.Install.LoaderVersion, c.Install.InstallerURL)
+			}
+			if c.Install.InstallLoader == nil || !*c.Install.InstallLoader {
+				t.Error("installLoader isn't true")
+			}
+			if c.Install.InstallForge || len(c.Install.ForgeVersion) > 0 {
+				t.Errorf("Forge is still installed: %+v", c.Install)
+			}
+		}},
+		{"Forge replaces the Fabric installer on spec 2", testLoaderManifest("1.16.5", "forge-36.2.0"), testFabricConfig, false, func(t *testing.T, c ServerSetupConfig) {
+			if c.Specver != 2 || c.Install.LoaderVersion != "36.2.0" || c.Install.InstallerURL != forgeLoaderInstallerURL {
+				t.Errorf("got spec %d, loader %q, installer %q", c.Specver, c.Install.LoaderVersion, c.Install.InstallerURL)
+			}
+			if strings.Join(c.Install.InstallerArguments, " ") != strings.Join(forgeInstallerArguments, " ") {
+				t.Errorf("got installer arguments %q", c.Install.InstallerArguments)
+			}
+		}},
+		{"custom Forge installer is kept", testLoaderManifest("1.16.5", "forge-36.2.0"), "_specver: 2\ninstall:\n  installerUrl: https://example.com/forge.jar\n", false, func(t *testing.T, c ServerSetupConfig) {
+			if c.Install.InstallerURL != "https://example.com/forge.jar" {
+				t.Errorf("got installer %q", c.Install.InstallerURL)
+			}
+		}},
+		{"custom Fabric installer is kept", testLoaderManifest("1.16.5", "fabric-0.11.6"), "_specver: 2\ninstall:\n  installerUrl: https://example.com/fabric-installer.jar\n", false, func(t *testing.T, c ServerSetupConfig) {
+			if c.Install.InstallerURL != "https://example.com/fabric-installer.jar" {
+				t.Errorf("got installer %q", c.Install.InstallerURL)
+			}
+		}},
+		{"set manually", testLoaderManifest("1.16.5", "fabric-0.11.6"), "_specver: 1\ninstall:\n  mcVersion: 1.12.2\n", true, func(t *testing.T, c ServerSetupConfig) {
+			if c.Specver != 1 || c.Install.McVersion != "1.12.2" || len(c.Install.LoaderVersion) > 0 {
+				t.Errorf("got spec %d, Minecraft %q, loader %q", c.Specver, c.Install.McVersion, c.Install.LoaderVersion)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack := testModpack(t, tt.manifest, tt.config)
+			pack.Settings.OverrideServerVersions = tt.override
+			pack.syncServerVersions()
+			tt.check(t, pack.ServerSetupConfig)
+			if drift := pack.serverVersionDrift(); len(drift) > 0 {
+				t.Errorf("server versions drift after syncing: %v", drift)
+			}
+		})
+	}
+}
+
+func TestServerVersionDriftFabricInstaller(t *testing.T) {
+	// Switching a spec 2 pack from Fabric to Forge is reported until it is saved
+	pack := testModpack(t, testLoaderManifest("1.16.5", "forge-36.2.0"), testFabricConfig)
+	var codes []string
+	for _, v := range pack.serverVersionDrift() {
+		codes = append(codes, v.Code)
+	}
+	if strings.Join(codes, ",") != LintLoaderVersionMismatch+","+LintLoaderVersionMismatch {
+		t.Errorf("got %q, want the loader version and installer reported", codes)
+	}
+}
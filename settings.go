@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// PackSettings are modpack-editor options stored per modpack, in the editor data folder
+type PackSettings struct {
+	// GitCommit commits the config files to the pack folder's git repository on every save
+	GitCommit bool
+	// OverrideServerVersions stops the ServerStarter Minecraft and modloader versions being set from the manifest on save
+	OverrideServerVersions bool
+}
+
+func packSettingsPath(packFolder string) string {
+	return filepath.Join(packFolder, editorDataFolder, "settings.json")
+}
+
+func loadPackSettings(packFolder string) (PackSettings, error) {
+	var settings PackSettings
+	data, err := ioutil.ReadFile(packSettingsPath(packFolder))
+	if os.IsNotExist(err) {
+		// Use defaults
+		return settings, nil
+	} else if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
+func (m *Modpack) savePackSettings() error {
+	data, err := json.MarshalIndent(&m.Settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(m.Folder, editorDataFolder), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(packSettingsPath(m.Folder), data, 0664)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reasons a mod is suggested as client only, from most to least certain
+const (
+	SideReasonKnown    = "known"
+	SideReasonJar      = "jar"
+	SideReasonCategory = "category"
+)
+
+// SideSuggestion suggests that a mod shouldn't be installed on the server
+type SideSuggestion struct {
+	ProjectID int
+	Name      string
+	Reason    string
+	Message   string
+}
+
+// knownClientOnlyMods are bundled project IDs of mods that only work on the client
+var knownClientOnlyMods = map[int]string{
+	32274:  "JourneyMap",
+	60089:  "Mouse Tweaks",
+	223094: "Inventory Tweaks",
+	232131: "Default Options",
+	238372: "Neat",
+	250398: "Controlling",
+	263420: "Xaero's Minimap",
+	317780: "Xaero's World Map",
+}
+
+// clientOnlyCategories are CurseForge categories that are mostly client only mods
+var clientOnlyCategories = map[string]bool{
+	"Map and Information": true,
+}
+
+// clientOnlyListPath is a user editable list of client only project IDs, one per line.
+// Lines starting with # are comments, and IDs starting with ! remove a bundled ID.
+var clientOnlyListPath string
+
+// maxJarSize is the largest jar downloaded to check the sides it declares
+const maxJarSize = 64 * 1024 * 1024
+
+// maxJarDownloads is how many mod jars are downloaded at once when checking sides
+const maxJarDownloads = 4
+
+// jarDownloads limits the jars being downloaded to maxJarDownloads
+var jarDownloads = make(chan struct{}, maxJarDownloads)
+
+// jarSides caches the environment declared by jars, keyed by file ID
+var jarSides = struct {
+	sync.Mutex
+	environments map[int]string
+}{environments: make(map[int]string)}
+
+// defaultClientOnlyListPath returns the list in the user config folder, or an empty string if there isn't one
+func defaultClientOnlyListPath() string {
+	folder, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(folder, "modpack-editor", "client-only-mods.txt")
+}
+
+// loadClientOnlyMods returns the bundled client only mods, changed by the user's list.
+// The list is read every time, so edits apply without restarting.
+func loadClientOnlyMods() map[int]string {
+	mods := make(map[int]string, len(knownClientOnlyMods))
+	for k, v := range knownClientOnlyMods {
+		mods[k] = v
+	}
+	if len(clientOnlyListPath) == 0 {
+		return mods
+	}
+
+	file, err := os.Open(clientOnlyListPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Print("Error reading client only mod list:")
+			log.Print(err)
+		}
+		return mods
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		remove := strings.HasPrefix(line, "!")
+		// Anything after the ID, such as the mod name, is ignored
+		fields := strings.Fields(strings.TrimPrefix(line, "!"))
+		if len(fields) == 0 {
+			continue
+		}
+		projectID, err := strconv.Atoi(fields[0])
+		if err != nil {
+			log.Printf("Invalid project ID in client only mod list: %s", line)
+			continue
+		}
+		if remove {
+			delete(mods, projectID)
+		} else {
+			mods[projectID] = ""
+		}
+	}
+	return mods
+}
+
+// suggestSides suggests which of the given mods shouldn't be on the server.
+// Only mods on both the client and the server are checked. If projectIDs is nil, every mod is checked.
+func (m *Modpack) suggestSides(projectIDs []int, checkJars bool) []SideSuggestion {
+	if projectIDs == nil {
+		for k := range m.Mods {
+			projectIDs = append(projectIDs, k)
+		}
+	}
+	clientOnly := loadClientOnlyMods()
+
+	var suggestions []SideSuggestion
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, projectID := range projectIDs {
+		mod, ok := m.Mods[projectID]
+		if !ok || mod.Error != nil || !mod.OnClient || !mod.OnServer {
+			continue
+		}
+
+		wg.Add(1)
+		go func(projectID int, mod ModInfo) {
+			defer wg.Done()
+			reason, message := clientOnlyReason(projectID, mod.FileID, clientOnly, checkJars)
+			if len(reason) == 0 {
+				return
+			}
+			mutex.Lock()
+			suggestions = append(suggestions, SideSuggestion{projectID, mod.Name, reason, message})
+			mutex.Unlock()
+		}(projectID, mod)
+	}
+	wg.Wait()
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions
+}
+
+// clientOnlyReason returns why a mod looks client only, or an empty reason if it doesn't
+func clientOnlyReason(projectID, fileID int, clientOnly map[int]string, checkJars bool) (string, string) {
+	if _, ok := clientOnly[projectID]; ok {
+		return SideReasonKnown, "This mod is in the list of client only mods"
+	}
+
+	if checkJars {
+		environment, err := jarEnvironment(projectID, fileID)
+		if err != nil {
+			log.Printf("Error checking the sides of project %d:", projectID)
+			log.Print(err)
+		} else if environment == "client" {
+			return SideReasonJar, "The mod jar says it only runs on the client"
+		}
+	}
+
+	data, err := requestAddonData(projectID)
+	if err != nil {
+		return "", ""
+	}
+	for _, v := range data.Categories {
+		if clientOnlyCategories[v.Name] {
+			return SideReasonCategory, fmt.Sprintf("Mods in the %s category are usually client only", v.Name)
+		}
+	}
+	return "", ""
+}
+
+// jarEnvironment downloads a mod file and returns the environment in its fabric.mod.json,
+// or an empty string if it doesn't declare one
+func jarEnvironment(projectID, fileID int) (string, error) {
+	jarSides.Lock()
+	environment, ok := jarSides.environments[fileID]
+	jarSides.Unlock()
+	if ok {
+		return environment, nil
+	}
+	if offlineMode {
+		return "", errors.New("Can't download mod jars in offline mode")
+	}
+
+	fileData, err := requestFileData(projectID, fileID)
+	if err != nil {
+		return "", err
+	}
+	if len(fileData.DownloadURL) == 0 {
+		return "", errors.New("File has no download URL")
+	}
+
+	jar, size, err := downloadJar(fileData)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		jar.Close()
+		os.Remove(jar.Name())
+	}()
+
+	zr, err := zip.NewReader(jar, size)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range zr.File {
+		if v.Name != "fabric.mod.json" {
+			continue
+		}
+		rc, err := v.Open()
+		if err != nil {
+			return "", err
+		}
+		var fabricMod struct {
+			Environment string `json:"environment"`
+		}
+		err = json.NewDecoder(rc).Decode(&fabricMod)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		environment = fabricMod.Environment
+		break
+	}
+
+	jarSides.Lock()
+	jarSides.environments[fileID] = environment
+	jarSides.Unlock()
+	return environment, nil
+}
+
+// downloadJar downloads a mod file into a temporary file, which the caller must remove
+func downloadJar(fileData FileData) (*os.File, int64, error) {
+	jarDownloads <- struct{}{}
+	defer func() { <-jarDownloads }()
+
+	resp, err := http.Get(fileData.DownloadURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, 0, fmt.Errorf("Downloading %s failed: %s", fileData.FileName, resp.Status)
+	}
+
+	jar, err := ioutil.TempFile("", "modpack-editor-*.jar")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(jar, io.LimitReader(resp.Body, maxJarSize+1))
+	if err == nil && size > maxJarSize {
+		err = fmt.Errorf("%s is too big to check", fileData.FileName)
+	}
+	if err != nil {
+		jar.Close()
+		os.Remove(jar.Name())
+		return nil, 0, err
+	}
+	return jar, size, nil
+}
+
+// applySideSuggestions removes the suggested mods from the server
+func (m *Modpack) applySideSuggestions(suggestions []SideSuggestion) {
+	for _, v := range suggestions {
+		if mod, ok := m.Mods[v.ProjectID]; ok {
+			mod.OnClient = true
+			mod.OnServer = false
+			m.Mods[v.ProjectID] = mod
+		}
+	}
+}
+
+// suggestSidesHandler suggests client only mods in the modpack being edited, and applies them if apply is true
+func suggestSidesHandler(w http.ResponseWriter, newPack Modpack, checkJars, apply bool) {
+	if newPack.Mods == nil {
+		writeError(w, errors.New("No mods given"))
+		return
+	}
+	// Only Fabric mods say which side they run on in their jar
+	if loader, _, _ := newPack.primaryModLoader(); checkJars && loader != "fabric" {
+		writeError(w, errors.New("Mod jars can only be checked in Fabric modpacks"))
+		return
+	}
+
+	suggestions := newPack.suggestSides(nil, checkJars)
+	if apply {
+		newPack.applySideSuggestions(suggestions)
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Suggestions []SideSuggestion
+		Mods        map[int]ModInfo
+	}{suggestions, newPack.Mods})
+}
@@ -1,9 +1,17 @@
 let currentModpack;
+// The ID of the modpack in the server's workspace, also kept in the URL hash
+let currentModpackID;
 let deleteConfirmation = null;
 let currentModKeysSorted = [];
+// Counts of mods loaded, failed and remaining while the mod list loads
+let modLoadProgress = null;
+// Files that can be chosen for a mod, by project ID, while its file choice is open
+let modFileChoices = {};
 
 // TODO: support SSC description?
-// TODO: support custom server config of mcVersion and forge?
+
+// Diagnostic codes for server versions that don't match the manifest
+const versionDriftCodes = ["mcVersionMismatch", "forgeVersionMismatch", "loaderVersionMismatch"];
 
 // Go equates [] and null, JavaScript does not.
 function nullableArray(array) {
@@ -87,6 +95,13 @@ const generalInputHandlers = [
 			});
 			return value.map(a => a.id).join(",");
 		}
+	},
+	{
+		id: "gitCommit",
+		label: "Commit to git on save",
+		type: "checkbox",
+		handler: e => currentModpack.Settings.GitCommit = e.target.checked,
+		get: () => currentModpack.Settings.GitCommit
 	}
 ];
 
@@ -99,6 +114,43 @@ const serverInputHandlers = [
 		get: () => currentModpack.ServerSetupConfig.Install.ModpackURL
 	},
 	// TODO: IgnoreProject (with mod list?)
+	{
+		id: "overrideServerVersions",
+		label: "Set the server Minecraft and modloader versions manually, instead of from the manifest",
+		type: "checkbox",
+		handler: e => currentModpack.Settings.OverrideServerVersions = e.target.checked,
+		get: () => currentModpack.Settings.OverrideServerVersions
+	},
+	{
+		id: "serverMcVersion",
+		label: "Server Minecraft version",
+		handler: e => currentModpack.ServerSetupConfig.Install.McVersion = e.target.value,
+		get: () => currentModpack.ServerSetupConfig.Install.McVersion
+	},
+	{
+		id: "serverForgeVersion",
+		label: "Server Forge version (leave empty to use the manifest)",
+		handler: e => currentModpack.ServerSetupConfig.Install.ForgeVersion = e.target.value,
+		get: () => currentModpack.ServerSetupConfig.Install.ForgeVersion
+	},
+	{
+		id: "forgeInstallerURL",
+		label: "Forge installer URL (leave empty for the default)",
+		handler: e => currentModpack.ServerSetupConfig.Install.ForgeInstallerURL = e.target.value,
+		get: () => currentModpack.ServerSetupConfig.Install.ForgeInstallerURL
+	},
+	{
+		id: "serverLoaderVersion",
+		label: "Server modloader version (ServerStarter 2, used for Fabric)",
+		handler: e => currentModpack.ServerSetupConfig.Install.LoaderVersion = e.target.value,
+		get: () => currentModpack.ServerSetupConfig.Install.LoaderVersion
+	},
+	{
+		id: "installerURL",
+		label: "Modloader installer URL (ServerStarter 2, used for Fabric)",
+		handler: e => currentModpack.ServerSetupConfig.Install.InstallerURL = e.target.value,
+		get: () => currentModpack.ServerSetupConfig.Install.InstallerURL
+	},
 	{
 		id: "baseInstallPath",
 		label: "Installation path (leave empty for current path)",
@@ -127,6 +179,13 @@ const serverInputHandlers = [
 		handler: e => currentModpack.ServerSetupConfig.Install.InstallForge = e.target.checked,
 		get: () => currentModpack.ServerSetupConfig.Install.InstallForge
 	},
+	{
+		id: "installLoader",
+		label: "Install modloader (ServerStarter 2, used for Fabric)",
+		type: "checkbox",
+		handler: e => currentModpack.ServerSetupConfig.Install.InstallLoader = e.target.checked,
+		get: () => currentModpack.ServerSetupConfig.Install.InstallLoader
+	},
 	{
 		id: "spongeFix",
 		label: "Apply launch wrapper to fix Sponge",
@@ -233,17 +292,32 @@ function updateModList() {
 function renderModListContent() {
 	const modListLink = document.getElementById("modListLink");
 
-	modListLink.innerText = "Mod list (" + currentModKeysSorted.length + " mods)";
+	if (currentModpack.LoadingMods && modLoadProgress) {
+		const total = modLoadProgress.Done + modLoadProgress.Failed + modLoadProgress.Remaining;
+		modListLink.innerText = "Mod list (loading " + (total - modLoadProgress.Remaining) + " of " + total + ", " + modLoadProgress.Failed + " failed)";
+	} else if (currentModpack.LoadingMods) {
+		modListLink.innerText = "Mod list (loading...)";
+	} else {
+		modListLink.innerText = "Mod list (" + currentModKeysSorted.length + " mods)";
+	}
+
+	// Problems with a project are shown with the mod, the rest are shown first
+	const diagnostics = (currentModpack.Diagnostics || []).filter(diagnostic => !diagnostic.ProjectID).map(diagnostic => hyperHTML.wire(diagnostic)`
+		<li class="${"list-group-item " + (diagnostic.Severity == "error" ? "list-group-item-danger" : "list-group-item-warning")}">
+			<h5 class="mb-1">${diagnostic.URL ? diagnostic.URL : "Modpack"}</h5>
+			<p class="mb-1">${diagnostic.Message}</p>
+		</li>
+	`);
 
-	return currentModKeysSorted.map(currentModID => {
+	return diagnostics.concat(currentModKeysSorted.map(currentModID => {
 		let currentModData = currentModpack.Mods[currentModID];
-		if (!currentModData || currentModData.ErrorMessage) {
+		if (!currentModData || currentModData.Error) {
 			return hyperHTML.wire(currentModData)`
 			<li class="list-group-item list-group-item-warning flex-row d-flex">
 				<img src="data:image/gif;base64,R0lGODlhAQABAAD/ACwAAAAAAQABAAACADs=" class="img-thumbnail modIcon mr-2">
 				<div class="flex-fill">
 					<h5 class="mb-1">An error occurred (project id ${currentModID})</h5>
-					<p class="mb-1">${currentModData ? currentModData.ErrorMessage : ""}</p>
+					<p class="mb-1">${currentModData ? currentModData.Error.Message : ""}</p>
 				</div>
 			</li>
 			`;
@@ -333,27 +407,114 @@ function renderModListContent() {
 			updateModList();
 		};
 
+		let toggleRequired = () => {
+			currentModData.Required = !currentModData.Required;
+			updateModList();
+		};
+
+		let showFiles = () => {
+			fetch("/ajax/getModFiles", {
+				method: "post",
+				headers: {
+					"Content-type": "application/json; charset=UTF-8"
+				},
+				body: JSON.stringify({
+					"ID": currentModpackID,
+					"Modpack": currentModpack,
+					"ProjectID": parseInt(currentModID)
+				})
+			}).then(response => response.json()).then(function(data) {
+				if (data.ErrorMessage) {
+					logSaveError(data.ErrorMessage);
+					return;
+				}
+				modFileChoices[currentModID] = data.Files;
+				updateModList();
+			}).catch(function(error) {
+				logSaveError(error);
+			});
+		};
+
+		let changeFile = e => {
+			if (e.target.value) {
+				currentModData.FileID = parseInt(e.target.value);
+			}
+			delete modFileChoices[currentModID];
+			updateModList();
+		};
+
+		let fileChoice = "";
+		if (modFileChoices[currentModID]) {
+			const files = modFileChoices[currentModID];
+			fileChoice = hyperHTML.wire(files)`
+			<select class="form-control form-control-sm mt-1" onchange="${changeFile}">
+				<option value="">${files.length ? "Keep the current file" : "No files for this modloader and Minecraft version"}</option>
+				${files.map(file => hyperHTML.wire(file)`
+					<option value="${file.id}" selected="${file.id == currentModData.FileID}">${file.fileName + " (" + file.releaseType + ")"}</option>
+				`)}
+			</select>
+			`;
+		}
+
+		const modDiagnostics = (currentModpack.Diagnostics || []).filter(diagnostic => diagnostic.ProjectID == currentModID).map(diagnostic => hyperHTML.wire(diagnostic)`
+			<p class="mb-1 text-warning">${diagnostic.Message}</p>
+		`);
+
 		return hyperHTML.wire(currentModData)`
 		<li class="list-group-item flex-row d-flex">
 			<img src="${iconURL}" class="img-thumbnail modIcon mr-2">
 			<div class="flex-fill">
 				<div class="d-flex justify-content-between">
-					<h5 class="mb-1"><a href="${websiteURL}">${currentModData.Name}</a></h5>
+					<h5 class="mb-1"><a href="${websiteURL}">${currentModData.Name}</a> <span class="${"badge badge-secondary" + (currentModData.Stale ? "" : " d-none")}" title="This mod info is out of date, and can't be updated in offline mode">Stale</span></h5>
 					<div>
 						<div role="group" aria-label="Client/Server selection" class="btn-group">
 							<button type="button" class="${"btn btn-sm " + (currentModData.OnClient ? "btn-primary active": "btn-outline-primary")}" onclick="${toggleClient}">Client</button>
 							<button type="button" class="${"btn btn-sm " + (currentModData.OnServer ? "btn-primary active": "btn-outline-primary")}" onclick="${toggleServer}">Server</button>
 						</div>
+						<button type="button" class="${"btn btn-sm " + (currentModData.Required ? "btn-outline-secondary" : "btn-secondary active") + (currentModData.OnClient ? "" : " d-none")}" title="Optional mods can be turned off by players in their launcher" onclick="${toggleRequired}">Optional</button>
+						<button type="button" class="btn btn-outline-secondary btn-sm" onclick="${showFiles}">Change file</button>
 						<button type="button" class="btn btn-outline-danger btn-sm" onclick="${removeMod}">Remove</button>
 					</div>
 				</div>
 				<p class="mb-1">${currentModData.Summary}</p>
+				${modDiagnostics}
+				${fileChoice}
 			</div>
 		</li>
 		`;
+	}));
+}
+
+function sortModKeys() {
+	currentModKeysSorted = Object.keys(currentModpack.Mods);
+
+	// Only sort when editor is loaded to improve performance on deletes/additions
+	// Use insertion sort when mods are being added
+	currentModKeysSorted.sort((a, b) => {
+		// Push missing projects to the top
+		if (!currentModpack.Mods[a] || currentModpack.Mods[a].Error) {
+			return -1;
+		} else if (!currentModpack.Mods[b] || currentModpack.Mods[b].Error) {
+			return 1;
+		}
+		return currentModpack.Mods[a].Name.localeCompare(currentModpack.Mods[b].Name);
 	});
 }
 
+// Add mods sent by the server while the mod list loads, without replacing ones already here
+function mergeLoadedMods(mods) {
+	for (const projectID of Object.keys(mods)) {
+		if (currentModpack.Mods[projectID]) {
+			// Dependants are only known once every mod is loaded
+			currentModpack.Mods[projectID].Dependants = mods[projectID].Dependants;
+		} else {
+			currentModpack.Mods[projectID] = mods[projectID];
+		}
+	}
+	sortModKeys();
+	updateModList();
+}
+
 function loadEditor() {
 	renderForm();
 
@@ -362,20 +523,7 @@ function loadEditor() {
 	<ul class="list-group">
 		${{
 			any: new Promise((resolve, reject) => {
-				currentModKeysSorted = Object.keys(currentModpack.Mods);
-
-				// Only sort when editor is loaded to improve performance on deletes/additions
-				// Use insertion sort when mods are being added
-				currentModKeysSorted.sort((a, b) => {
-					// Push missing projects to the top
-					if (!currentModpack.Mods[a] || currentModpack.Mods[a].ErrorMessage) {
-						return -1;
-					} else if (!currentModpack.Mods[b] || currentModpack.Mods[b].ErrorMessage) {
-						return 1;
-					}
-					return currentModpack.Mods[a].Name.localeCompare(currentModpack.Mods[b].Name);
-				});
-				
+				sortModKeys();
 				resolve(renderModListContent());
 			}),
 			placeholder: "Loading mod list..."
@@ -405,14 +553,96 @@ function showOpenSuccess(isCreated) {
 	statusElement.innerText = isCreated ? "Successfully created modpack!" : "Successfully opened modpack!";
 	statusElement.className = "text-success";
 	reloadModpackButtonElement.disabled = false;
+	previewModpackButtonElement.disabled = false;
+	refreshModInfoButtonElement.disabled = false;
+	lintModpackButtonElement.disabled = false;
+	cloneModpackButtonElement.disabled = false;
+	mergeModpackButtonElement.disabled = false;
+	suggestSidesButtonElement.disabled = false;
+	modLoaderVersionsButtonElement.disabled = false;
 	saveModpackButtonElement.disabled = false;
 }
 
+function showStatus(message) {
+	statusElement.innerText = message;
+	statusElement.className = "text-info";
+}
+
 function showSaveSuccess() {
 	statusElement.innerText = "Successfully saved modpack!";
 	statusElement.className = "text-success";
 }
 
+let eventSource = null;
+
+function setCurrentModpack(id, modpack) {
+	currentModpackID = id;
+	currentModpack = modpack;
+	modFileChoices = {};
+	// Keep the ID in the URL, so this tab reopens the same modpack
+	history.replaceState(null, "", "#" + id);
+
+	// Listen for changes made outside the editor
+	if (eventSource != null) {
+		eventSource.close();
+	}
+	eventSource = new EventSource("/events?ID=" + encodeURIComponent(id));
+	eventSource.addEventListener("open", () => {
+		if (!currentModpack.LoadingMods) {
+			return;
+		}
+		// Catch up with mods loaded before the stream was opened
+		fetch("/ajax/getCurrentPackDetails", {
+			method: "post",
+			headers: {
+				"Content-type": "application/json; charset=UTF-8"
+			},
+			body: JSON.stringify({
+				"ID": currentModpackID
+			})
+		}).then(response => response.json()).then(function(data) {
+			if (data.Modpack == null || data.ID != currentModpackID) {
+				return;
+			}
+			currentModpack.LoadingMods = data.Modpack.LoadingMods;
+			mergeLoadedMods(data.Modpack.Mods);
+		});
+	});
+	eventSource.addEventListener("modInfo", e => {
+		const progress = JSON.parse(e.data).Data;
+		modLoadProgress = progress;
+		if (progress.ProjectID == 0 && progress.ModInfo.Error) {
+			currentModpack.Diagnostics = (currentModpack.Diagnostics || []).concat([progress.ModInfo.Error]);
+		}
+		if (progress.ProjectID != 0 && !currentModpack.Mods[progress.ProjectID]) {
+			currentModpack.Mods[progress.ProjectID] = progress.ModInfo;
+			sortModKeys();
+		}
+		updateModList();
+	});
+	eventSource.addEventListener("modListLoaded", e => {
+		const data = JSON.parse(e.data).Data;
+		currentModpack.LoadingMods = false;
+		currentModpack.Diagnostics = data.Diagnostics;
+		modLoadProgress = null;
+		mergeLoadedMods(data.Mods);
+	});
+	eventSource.addEventListener("modpackChanged", e => {
+		const data = JSON.parse(e.data);
+		// Unsaved edits are kept unless the user chooses to reload, saving them then reports the conflict
+		if (!confirm("The modpack was changed outside the editor. Reload it? Changes you haven't saved will be lost.")) {
+			showStatus("The modpack was changed outside the editor. Your changes were kept, saving them will show what changed.");
+			return;
+		}
+		currentModpack = data.Data.Modpack;
+		loadEditor();
+		showStatus("The modpack was changed outside the editor, and has been reloaded.");
+	});
+	eventSource.addEventListener("overridesChanged", () => {
+		showStatus("Files in the overrides folder were changed outside the editor.");
+	});
+}
+
 // Modpack opening UI
 const modpackLocationInput = document.getElementById("modpackLocation");
 const saveModpackButtonElement = document.getElementById("saveModpackButton");
@@ -432,13 +662,29 @@ openModpackButtonElement.addEventListener("click", () => {
 			return;
 		}
 		showOpenSuccess(false);
-		currentModpack = data.Modpack;
+		setCurrentModpack(data.ID, data.Modpack);
 		loadEditor();
 	}).catch(function(error) {
 		logOpenError(error);
 	});
 }, false);
 
+// Templates new modpacks can be created from
+const newModpackTemplateElement = document.getElementById("newModpackTemplate");
+fetch("/ajax/listTemplates", {
+	method: "post"
+}).then(response => response.json()).then(function(data) {
+	if (data.ErrorMessage) {
+		logOpenError(data.ErrorMessage);
+		return;
+	}
+	hyperHTML.bind(newModpackTemplateElement)`${data.Templates.map(template => hyperHTML.wire(template)`
+		<option value="${template.Name}" title="${template.Folder}">${template.Name}</option>
+	`)}`;
+}).catch(function(error) {
+	logOpenError(error);
+});
+
 const newModpackButtonElement = document.getElementById("newModpackButton");
 newModpackButtonElement.addEventListener("click", () => {
 	fetch("/ajax/createModpackFolder", {
@@ -447,7 +693,14 @@ newModpackButtonElement.addEventListener("click", () => {
 			"Content-type": "application/json; charset=UTF-8"
 		},
 		body: JSON.stringify({
-			"Folder": modpackLocationInput.value
+			"Folder": modpackLocationInput.value,
+			"Template": newModpackTemplateElement.value,
+			"Variables": {
+				"Name": document.getElementById("newModpackName").value,
+				"Author": document.getElementById("newModpackAuthor").value,
+				"McVersion": document.getElementById("newModpackMcVersion").value,
+				"ModLoader": document.getElementById("newModpackModLoader").value
+			}
 		})
 	}).then(response => response.json()).then(function(data) {
 		if (data.ErrorMessage) {
@@ -455,7 +708,7 @@ newModpackButtonElement.addEventListener("click", () => {
 			return;
 		}
 		showOpenSuccess(true);
-		currentModpack = data.Modpack;
+		setCurrentModpack(data.ID, data.Modpack);
 		loadEditor();
 	}).catch(function(error) {
 		logOpenError(error);
@@ -482,7 +735,7 @@ reloadModpackButtonElement.addEventListener("click", () => {
 			return;
 		}
 		showOpenSuccess(false);
-		currentModpack = data.Modpack;
+		setCurrentModpack(data.ID, data.Modpack);
 		loadEditor();
 	}).catch(function(error) {
 		logOpenError(error);
@@ -490,7 +743,15 @@ reloadModpackButtonElement.addEventListener("click", () => {
 }, false);
 
 // Load current modpack
-fetch("/ajax/getCurrentPackDetails").then(response => response.json()).then(function(data) {
+fetch("/ajax/getCurrentPackDetails", {
+	method: "post",
+	headers: {
+		"Content-type": "application/json; charset=UTF-8"
+	},
+	body: JSON.stringify({
+		"ID": location.hash.slice(1)
+	})
+}).then(response => response.json()).then(function(data) {
 	if (data.ErrorMessage) {
 		logOpenError(data.ErrorMessage);
 		return;
@@ -500,7 +761,7 @@ fetch("/ajax/getCurrentPackDetails").then(response => response.json()).then(func
 	}
 	showOpenSuccess(false);
 	modpackLocationInput.value = data.Modpack.Folder;
-	currentModpack = data.Modpack;
+	setCurrentModpack(data.ID, data.Modpack);
 	loadEditor();
 }).catch(function(error) {
 	logOpenError(error);
@@ -519,19 +780,409 @@ saveModpackButtonElement.addEventListener("click", () => {
 			"Content-type": "application/json; charset=UTF-8"
 		},
 		body: JSON.stringify({
+			"ID": currentModpackID,
 			"Modpack": currentModpack
 		})
 	}).then(response => response.json()).then(function(data) {
+		if (data.Conflict) {
+			logSaveError(data.ErrorMessage + " (" + data.Conflict.Summary + ")");
+			previewOutputElement.innerText = data.Conflict.Diff;
+			previewOutputElement.classList.remove("d-none");
+			return;
+		}
 		if (data.ErrorMessage) {
+			// Errors after the files were written still give the saved revision
+			if (data.Revision) {
+				currentModpack.Revision = data.Revision;
+			}
 			logSaveError(data.ErrorMessage);
 			return;
 		}
+		currentModpack.Revision = data.Revision;
+		currentModpack.ServerSetupConfig = data.ServerSetupConfig;
+		currentModpack.Diagnostics = nullableArray(currentModpack.Diagnostics).filter(diagnostic => !versionDriftCodes.includes(diagnostic.Code)).concat(nullableArray(data.VersionDrift));
+		loadEditor();
+		previewOutputElement.classList.add("d-none");
 		showSaveSuccess();
+		if (data.SideSuggestions) {
+			statusElement.innerText += " These added mods look client only: " + data.SideSuggestions.map(suggestion => suggestion.Name).join(", ") +
+				". Use \"Remove client only mods from server\" to take them off the server.";
+		}
+	}).catch(function(error) {
+		logSaveError(error);
+	});
+}, false);
+
+// Preview changes
+const previewModpackButtonElement = document.getElementById("previewModpackButton");
+const previewOutputElement = document.getElementById("previewOutput");
+previewModpackButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to preview changes.")
+		return;
+	}
+
+	fetch("/ajax/previewSave", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Modpack": currentModpack
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		previewOutputElement.innerText = data.Summary + "\n\n" + data.Diff;
+		previewOutputElement.classList.remove("d-none");
+	}).catch(function(error) {
+		logSaveError(error);
+	});
+}, false);
+
+// Check for problems
+const lintModpackButtonElement = document.getElementById("lintModpackButton");
+lintModpackButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to check for problems.")
+		return;
+	}
+
+	fetch("/ajax/lintModpack", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Modpack": currentModpack
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		if (!data.Diagnostics) {
+			previewOutputElement.innerText = "No problems found.";
+		} else {
+			previewOutputElement.innerText = data.Diagnostics.map(diagnostic => {
+				const project = diagnostic.ProjectID ? " (project " + diagnostic.ProjectID + ")" : "";
+				return diagnostic.Severity + ": " + diagnostic.Message + project;
+			}).join("\n");
+		}
+		previewOutputElement.classList.remove("d-none");
+	}).catch(function(error) {
+		logSaveError(error);
+	});
+}, false);
+
+// Suggest and apply client only mods
+const suggestSidesButtonElement = document.getElementById("suggestSidesButton");
+suggestSidesButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to check for client only mods.")
+		return;
+	}
+
+	fetch("/ajax/suggestSides", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Modpack": currentModpack,
+			"Apply": true
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		if (!data.Suggestions) {
+			previewOutputElement.innerText = "No client only mods found on the server.";
+		} else {
+			for (const suggestion of data.Suggestions) {
+				currentModpack.Mods[suggestion.ProjectID].OnClient = true;
+				currentModpack.Mods[suggestion.ProjectID].OnServer = false;
+			}
+			updateModList();
+			previewOutputElement.innerText = "Removed from the server (not saved yet):\n" + data.Suggestions.map(suggestion => {
+				return suggestion.Name + ": " + suggestion.Message;
+			}).join("\n");
+		}
+		previewOutputElement.classList.remove("d-none");
 	}).catch(function(error) {
 		logSaveError(error);
 	});
 }, false);
 
+// List modloader versions, and check the ones in the manifest
+const modLoaderVersionsButtonElement = document.getElementById("modLoaderVersionsButton");
+modLoaderVersionsButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to list modloader versions.")
+		return;
+	}
+
+	fetch("/ajax/listModLoaderVersions", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Modpack": currentModpack
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		const problems = nullableArray(data.Diagnostics).map(diagnostic => diagnostic.Severity + ": " + diagnostic.Message);
+		const versions = nullableArray(data.Versions).map(version => {
+			let marks = [];
+			if (version.Recommended) {
+				marks.push("recommended");
+			}
+			if (version.Latest) {
+				marks.push("latest");
+			}
+			return version.ID + (marks.length ? " (" + marks.join(", ") + ")" : "");
+		});
+		previewOutputElement.innerText = problems.concat(problems.length ? [""] : [], versions).join("\n");
+		previewOutputElement.classList.remove("d-none");
+		return fetch("/ajax/fillForgeInstallerURL", {
+			method: "post",
+			headers: {
+				"Content-type": "application/json; charset=UTF-8"
+			},
+			body: JSON.stringify({
+				"ID": currentModpackID,
+				"Modpack": currentModpack
+			})
+		}).then(response => response.json()).then(function(data) {
+			// Only offer the installer URL for known Forge versions
+			if (data.ForgeInstallerURL && currentModpack.ServerSetupConfig.Install.ForgeInstallerURL != data.ForgeInstallerURL &&
+				confirm("Set the Forge installer URL to " + data.ForgeInstallerURL + "?")) {
+				currentModpack.ServerSetupConfig.Install.ForgeInstallerURL = data.ForgeInstallerURL;
+				renderForm();
+			}
+		});
+	}).catch(function(error) {
+		logSaveError(error);
+	});
+}, false);
+
+// Fetch mod info again, ignoring the cache
+const refreshModInfoButtonElement = document.getElementById("refreshModInfoButton");
+refreshModInfoButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to refresh mod info.")
+		return;
+	}
+
+	refreshModInfoButtonElement.disabled = true;
+	showStatus("Refreshing mod info...");
+	fetch("/ajax/refreshCache", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID
+		})
+	}).then(response => response.json()).then(function(data) {
+		refreshModInfoButtonElement.disabled = false;
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		for (const projectID of Object.keys(data.Mods)) {
+			const mod = currentModpack.Mods[projectID];
+			if (!mod) {
+				continue;
+			}
+			// Keep unsaved side, file and required changes
+			const refreshed = data.Mods[projectID];
+			refreshed.OnClient = mod.OnClient;
+			refreshed.OnServer = mod.OnServer;
+			refreshed.FileID = mod.FileID;
+			refreshed.Required = mod.Required;
+			currentModpack.Mods[projectID] = refreshed;
+		}
+		currentModpack.Diagnostics = data.Diagnostics;
+		sortModKeys();
+		updateModList();
+		showStatus("Refreshed " + data.Refreshed + " mods" + (data.Failed > 0 ? ", " + data.Failed + " failed." : "."));
+	}).catch(function(error) {
+		refreshModInfoButtonElement.disabled = false;
+		logSaveError(error);
+	});
+}, false);
+
+// Copy the saved modpack into the folder in the location box, and open the copy
+const cloneModpackButtonElement = document.getElementById("cloneModpackButton");
+cloneModpackButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logOpenError("Must open a modpack to clone it.")
+		return;
+	}
+	if (modpackLocationInput.value == currentModpack.Folder) {
+		logOpenError("Enter the location of the clone in the modpack location box.")
+		return;
+	}
+	const name = prompt("Name of the clone:", currentModpack.CurseManifest.name);
+	if (name === null) {
+		return;
+	}
+	const version = prompt("Version of the clone:", currentModpack.CurseManifest.version);
+	if (version === null) {
+		return;
+	}
+	let reset = ["name", "version"];
+	if (confirm("Clear the Curse project ID and pack download link in the clone?")) {
+		reset.push("projectID", "modpackUrl");
+	}
+
+	fetch("/ajax/cloneModpack", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Folder": modpackLocationInput.value,
+			"Clone": {
+				"Reset": reset,
+				"Name": name,
+				"Version": version
+			}
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logOpenError(data.ErrorMessage);
+			return;
+		}
+		showOpenSuccess(false);
+		setCurrentModpack(data.ID, data.Modpack);
+		loadEditor();
+	}).catch(function(error) {
+		logOpenError(error);
+	});
+}, false);
+
+// Merge mods from the modpack folder or zip in the location box
+const mergeModpackButtonElement = document.getElementById("mergeModpackButton");
+const mergeOutputElement = document.getElementById("mergeOutput");
+const mergeOutputBind = hyperHTML.bind(mergeOutputElement);
+mergeModpackButtonElement.addEventListener("click", () => {
+	if (currentModpack == null) {
+		logSaveError("Must open a modpack to merge into it.")
+		return;
+	}
+
+	const source = modpackLocationInput.value;
+	showStatus("Loading the mods to merge...");
+	fetch("/ajax/previewMerge", {
+		method: "post",
+		headers: {
+			"Content-type": "application/json; charset=UTF-8"
+		},
+		body: JSON.stringify({
+			"ID": currentModpackID,
+			"Modpack": currentModpack,
+			"Source": source
+		})
+	}).then(response => response.json()).then(function(data) {
+		if (data.ErrorMessage) {
+			logSaveError(data.ErrorMessage);
+			return;
+		}
+		showStatus("Choose what to merge from " + source);
+		renderMerge(source, data);
+	}).catch(function(error) {
+		logSaveError(error);
+	});
+}, false);
+
+function renderMerge(source, preview) {
+	// Resolutions by project ID, everything from the other modpack is taken unless unticked
+	let resolutions = {};
+	const resolution = projectID => {
+		if (!resolutions[projectID]) {
+			resolutions[projectID] = {ProjectID: projectID, File: false, Sides: false};
+		}
+		return resolutions[projectID];
+	};
+	const sidesText = (onClient, onServer) => onClient && onServer ? "client and server" : (onClient ? "client" : "server");
+	const entryList = (title, entries, field, describe) => {
+		entries = nullableArray(entries);
+		if (!entries.length) {
+			return "";
+		}
+		for (const entry of entries) {
+			resolution(entry.ProjectID)[field] = true;
+		}
+		return hyperHTML.wire(entries)`
+			<h5>${title}</h5>
+			${entries.map(entry => hyperHTML.wire(entry, ":" + field)`
+				<div class="form-check">
+					<input class="form-check-input" type="checkbox" id="${"merge-" + field + "-" + entry.ProjectID}" checked onchange="${e => resolution(entry.ProjectID)[field] = e.target.checked}">
+					<label class="form-check-label" for="${"merge-" + field + "-" + entry.ProjectID}">${entry.Name + ": " + describe(entry)}</label>
+				</div>
+			`)}
+		`;
+	};
+
+	const applyMerge = () => {
+		fetch("/ajax/applyMerge", {
+			method: "post",
+			headers: {
+				"Content-type": "application/json; charset=UTF-8"
+			},
+			body: JSON.stringify({
+				"ID": currentModpackID,
+				"Modpack": currentModpack,
+				"Source": source,
+				"Resolutions": Object.values(resolutions)
+			})
+		}).then(response => response.json()).then(function(data) {
+			if (data.ErrorMessage) {
+				logSaveError(data.ErrorMessage);
+				return;
+			}
+			currentModpack.Mods = data.Mods;
+			sortModKeys();
+			updateModList();
+			mergeOutputElement.classList.add("d-none");
+			showStatus("Merged mods from " + source + " (not saved yet).");
+		}).catch(function(error) {
+			logSaveError(error);
+		});
+	};
+	const cancelMerge = () => mergeOutputElement.classList.add("d-none");
+
+	const problems = nullableArray(preview.Diagnostics).map(diagnostic => hyperHTML.wire(diagnostic)`
+		<p class="mb-1 text-warning">${diagnostic.Message}</p>
+	`);
+	const nothingToMerge = !nullableArray(preview.Added).length && !nullableArray(preview.DifferentFiles).length && !nullableArray(preview.DifferentSides).length;
+	// Added projects are added with their file and sides when File is set
+	mergeOutputBind`
+		${problems}
+		<p class="${nothingToMerge ? "mb-1" : "d-none"}">The mods in both modpacks are the same.</p>
+		${entryList("Add projects", preview.Added, "File", entry => "on the " + sidesText(entry.OtherOnClient, entry.OtherOnServer))}
+		${entryList("Use the other file", preview.DifferentFiles, "File", entry => "file " + entry.CurrentFileID + " becomes " + entry.OtherFileID)}
+		${entryList("Use the other sides", preview.DifferentSides, "Sides", entry => sidesText(entry.CurrentOnClient, entry.CurrentOnServer) + " becomes " + sidesText(entry.OtherOnClient, entry.OtherOnServer))}
+		<button type="button" class="btn btn-outline-success btn-sm mt-2" onclick="${applyMerge}">Apply merge</button>
+		<button type="button" class="btn btn-outline-secondary btn-sm mt-2" onclick="${cancelMerge}">Cancel</button>
+	`;
+	mergeOutputElement.classList.remove("d-none");
+}
+
 // Tabbed UI
 function createTabbedUI(tabs, links) {
 	let tabElements = tabs.map((a) => document.getElementById(a));
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gobuffalo/packr"
+)
+
+// blankTemplateName is the name of the template built into the editor
+const blankTemplateName = "blank"
+
+// templateSuffix marks template files that are filled in with the variables
+const templateSuffix = ".tmpl"
+
+// templateFolders are folders containing pack templates, one per subfolder
+var templateFolders []string
+
+// templateFuncs quote variables for the file type they are used in, so names containing " or : are valid
+var templateFuncs = template.FuncMap{
+	"json": templateQuote,
+	// JSON strings are also valid YAML double quoted strings
+	"yaml": templateQuote,
+}
+
+// templateQuote returns a value as a quoted JSON string
+func templateQuote(value string) (string, error) {
+	quoted, err := json.Marshal(value)
+	return string(quoted), err
+}
+
+// PackTemplate is a template new modpacks can be created from
+type PackTemplate struct {
+	Name string
+	// Folder is empty for the built in template
+	Folder string
+}
+
+// PackTemplateVariables are filled into template files, and set in the new modpack if they aren't empty
+type PackTemplateVariables struct {
+	Name      string
+	Author    string
+	Version   string
+	McVersion string
+	// ModLoader is the primary modloader ID, such as forge-14.23.5.2854
+	ModLoader string
+}
+
+// defaultTemplateFolder returns the templates folder in the user config folder, or an empty string if there isn't one
+func defaultTemplateFolder() string {
+	folder, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(folder, "modpack-editor", "templates")
+}
+
+// listPackTemplates returns the built in template, then the templates in each template folder by name.
+// If a name is used more than once, the first template folder wins.
+func listPackTemplates() []PackTemplate {
+	templates := []PackTemplate{{Name: blankTemplateName}}
+	seen := map[string]bool{blankTemplateName: true}
+	for _, folder := range templateFolders {
+		files, err := ioutil.ReadDir(folder)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Print("Error reading template folder:")
+				log.Print(err)
+			}
+			continue
+		}
+		var found []PackTemplate
+		for _, v := range files {
+			if !v.IsDir() || seen[v.Name()] {
+				continue
+			}
+			seen[v.Name()] = true
+			found = append(found, PackTemplate{v.Name(), filepath.Join(folder, v.Name())})
+		}
+		sort.Slice(found, func(i, j int) bool {
+			return found[i].Name < found[j].Name
+		})
+		templates = append(templates, found...)
+	}
+	return templates
+}
+
+// findPackTemplate returns the template with a name, or the built in template if the name is empty
+func findPackTemplate(name string) (PackTemplate, error) {
+	if len(name) == 0 {
+		name = blankTemplateName
+	}
+	for _, v := range listPackTemplates() {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return PackTemplate{}, fmt.Errorf("There is no template called %s", name)
+}
+
+// copyTo writes the template's files into a folder. Files ending in .tmpl are filled in
+// with the variables, and written without the suffix.
+func (t PackTemplate) copyTo(folder string, variables PackTemplateVariables) error {
+	if len(t.Folder) == 0 {
+		return blankPackBox.Walk(func(fileName string, file packr.File) error {
+			return writeTemplateFile(filepath.Join(folder, fileName), file, variables)
+		})
+	}
+
+	return filepath.Walk(t.Folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(t.Folder, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(folder, relPath), os.ModePerm)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return writeTemplateFile(filepath.Join(folder, relPath), file, variables)
+	})
+}
+
+// rendersFile returns true if a file in the new modpack is filled in from a .tmpl file in the template
+func (t PackTemplate) rendersFile(fileName string) bool {
+	if len(t.Folder) == 0 {
+		return blankPackBox.Has(fileName + templateSuffix)
+	}
+	_, err := os.Stat(filepath.Join(t.Folder, fileName+templateSuffix))
+	return err == nil
+}
+
+// writeTemplateFile writes a file from a template, filling it in if it ends in .tmpl
+func writeTemplateFile(path string, file io.Reader, variables PackTemplateVariables) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(path, templateSuffix) {
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, file)
+		if err != nil {
+			return err
+		}
+		return out.Close()
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(strings.TrimSuffix(path, templateSuffix))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	err = tmpl.Execute(out, variables)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// saveTemplateVariables sets the variables that were given in the config files that weren't filled in
+// from .tmpl files, then reloads them. Saving the server config removes its comments, so nothing is
+// rewritten if no variables were given.
+func (m *Modpack) saveTemplateVariables(t PackTemplate, variables PackTemplateVariables) error {
+	if variables == (PackTemplateVariables{}) {
+		return nil
+	}
+	m.applyTemplateVariables(variables)
+	manifest, config, err := m.marshalConfigFiles()
+	if err != nil {
+		return err
+	}
+	if !t.rendersFile("manifest.json") {
+		err = ioutil.WriteFile(filepath.Join(m.Folder, "manifest.json"), manifest, 0664)
+		if err != nil {
+			return err
+		}
+	}
+	if !t.rendersFile("server-setup-config.yaml") {
+		err = ioutil.WriteFile(filepath.Join(m.Folder, "server-setup-config.yaml"), config, 0664)
+		if err != nil {
+			return err
+		}
+	}
+	return m.loadConfigFiles()
+}
+
+// applyTemplateVariables sets the variables that were given in the manifest and server config
+func (m *Modpack) applyTemplateVariables(variables PackTemplateVariables) {
+	if len(variables.Name) > 0 {
+		m.CurseManifest.Name = variables.Name
+		m.ServerSetupConfig.Modpack.Name = variables.Name
+	}
+	if len(variables.Author) > 0 {
+		m.CurseManifest.Author = variables.Author
+	}
+	if len(variables.Version) > 0 {
+		m.CurseManifest.Version = variables.Version
+	}
+	if len(variables.McVersion) > 0 {
+		m.CurseManifest.Minecraft.Version = variables.McVersion
+	}
+	if len(variables.ModLoader) > 0 {
+		// The template's modloaders are replaced, as they are for another version
+		m.CurseManifest.Minecraft.ModLoaders = append(m.CurseManifest.Minecraft.ModLoaders[:0], struct {
+			ID      string `json:"id"`
+			Primary bool   `json:"primary"`
+		}{variables.ModLoader, true})
+	}
+	m.syncServerVersions()
+}
+
+// listTemplates lists the templates new modpacks can be created from
+func listTemplates(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(struct {
+		Templates []PackTemplate
+	}{listPackTemplates()})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often open modpack folders are checked for changes made outside the editor
+const watchInterval = 2 * time.Second
+
+// watch polls the modpack's config files and overrides folder until stop is closed.
+// Changed config files are reloaded, and browsers are told about both kinds of change.
+func (p *openModpack) watch(stop chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	lastOverrides := ""
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.checkConfigFiles()
+
+		p.mutex.RLock()
+		overridesFolder := p.modpack.CurseManifest.Overrides
+		p.mutex.RUnlock()
+		overrides := folderFingerprint(filepath.Join(p.folder, overridesFolder))
+		if len(lastOverrides) > 0 && overrides != lastOverrides {
+			events.publish(Event{Type: "overridesChanged", ID: p.id})
+		}
+		lastOverrides = overrides
+	}
+}
+
+// checkConfigFiles reloads the modpack if its config files don't match the loaded revision
+func (p *openModpack) checkConfigFiles() {
+	// Read before the files, so a save finishing in between isn't mistaken for an outside change
+	p.mutex.RLock()
+	loadedRevision := p.modpack.Revision
+	p.mutex.RUnlock()
+
+	manifest, config, err := readConfigFiles(p.folder)
+	if err != nil {
+		// May be halfway through being written, try again next time
+		return
+	}
+	revision := packRevision(manifest, config)
+	if revision == loadedRevision {
+		return
+	}
+
+	reloadedPack := Modpack{Folder: p.folder}
+	err = reloadedPack.loadConfigFiles()
+	if err != nil {
+		// Probably an incomplete edit, try again next time
+		return
+	}
+	// Update mod list
+	reloadedPack.getModInfoList(nil)
+
+	p.mutex.Lock()
+	// If the editor saved while the mod list was loading, that save wins.
+	// The editor may also have saved the same files before they were read here.
+	if p.modpack.Revision != loadedRevision || p.modpack.Revision == reloadedPack.Revision {
+		p.mutex.Unlock()
+		return
+	}
+	p.modpack = reloadedPack
+	p.mutex.Unlock()
+
+	log.Printf("Reloaded %s after it was changed outside the editor", p.folder)
+	events.publish(Event{Type: "modpackChanged", ID: p.id, Data: struct {
+		Modpack Modpack
+	}{reloadedPack}})
+}
+
+// folderFingerprint hashes the names, sizes and modification times of every file in a folder
+func folderFingerprint(folder string) string {
+	hash := sha256.New()
+	filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Missing folders are fingerprinted as empty
+			return nil
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(hash.Sum(nil))
+}
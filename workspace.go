@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// openModpack is a modpack open in the editor. The folder never changes once opened.
+type openModpack struct {
+	id      string
+	folder  string
+	mutex   sync.RWMutex
+	modpack Modpack
+	// Closing stopWatching stops checking the folder for changes
+	stopWatching chan struct{}
+}
+
+// Workspace is the registry of modpacks open in the editor, keyed by ID
+type Workspace struct {
+	mutex      sync.RWMutex
+	packs      map[string]*openModpack
+	lastOpened string
+}
+
+var workspace = Workspace{packs: make(map[string]*openModpack)}
+
+// OpenModpackInfo describes an open modpack, for listing
+type OpenModpackInfo struct {
+	ID     string
+	Folder string
+	Name   string
+}
+
+func newModpackID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// get returns the open modpack with the given ID
+func (ws *Workspace) get(id string) (*openModpack, error) {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	if pack, ok := ws.packs[id]; ok {
+		return pack, nil
+	}
+	return nil, errors.New("Modpack is not open, it may have been closed")
+}
+
+// open adds a loaded modpack to the workspace and returns its ID.
+// If the folder is already open, it is replaced and keeps the same ID.
+func (ws *Workspace) open(m Modpack) string {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	for id, pack := range ws.packs {
+		if pack.folder == m.Folder {
+			pack.mutex.Lock()
+			pack.modpack = m
+			pack.mutex.Unlock()
+			ws.lastOpened = id
+			return id
+		}
+	}
+
+	id := newModpackID()
+	pack := &openModpack{
+		id:           id,
+		folder:       m.Folder,
+		modpack:      m,
+		stopWatching: make(chan struct{}),
+	}
+	ws.packs[id] = pack
+	go pack.watch(pack.stopWatching)
+	ws.lastOpened = id
+	ws.updateCache()
+	return id
+}
+
+// close removes a modpack from the workspace
+func (ws *Workspace) close(id string) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if pack, ok := ws.packs[id]; ok {
+		close(pack.stopWatching)
+	}
+	delete(ws.packs, id)
+	if ws.lastOpened == id {
+		ws.lastOpened = ""
+	}
+	ws.updateCache()
+}
+
+// updateCache stores the open folders in the cache, so they are opened again next time.
+// The workspace mutex must be held.
+func (ws *Workspace) updateCache() {
+	folders := make([]string, 0, len(ws.packs))
+	for _, v := range ws.packs {
+		folders = append(folders, v.folder)
+	}
+	sort.Strings(folders)
+	mainCache.openModpacksMutex.Lock()
+	mainCache.OpenModpacks = folders
+	mainCache.openModpacksMutex.Unlock()
+	markCacheChanged()
+}
+
+func (ws *Workspace) list() []OpenModpackInfo {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	list := make([]OpenModpackInfo, 0, len(ws.packs))
+	for id, pack := range ws.packs {
+		pack.mutex.RLock()
+		list = append(list, OpenModpackInfo{id, pack.folder, pack.modpack.CurseManifest.Name})
+		pack.mutex.RUnlock()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Folder < list[j].Folder
+	})
+	return list
+}
+
+// loadModInfoList loads the mod list in the background, sending each mod to browsers as it loads
+func (p *openModpack) loadModInfoList() {
+	p.mutex.RLock()
+	loadingPack := Modpack{
+		Folder:            p.modpack.Folder,
+		CurseManifest:     p.modpack.CurseManifest,
+		ServerSetupConfig: p.modpack.ServerSetupConfig,
+	}
+	revision := p.modpack.Revision
+	p.mutex.RUnlock()
+
+	loadingPack.getModInfoList(func(progress ModInfoProgress) {
+		p.mutex.Lock()
+		// Only update the modpack it was loaded from, not a reloaded one
+		if p.modpack.Revision == revision && p.modpack.LoadingMods && progress.ProjectID != 0 {
+			p.modpack.Mods[progress.ProjectID] = progress.ModInfo
+		}
+		p.mutex.Unlock()
+		events.publish(Event{Type: "modInfo", ID: p.id, Data: progress})
+	})
+
+	p.mutex.Lock()
+	if p.modpack.Revision == revision && p.modpack.LoadingMods {
+		// This also has dependants, which aren't known until every mod is loaded
+		p.modpack.Mods = loadingPack.Mods
+		p.modpack.Diagnostics = loadingPack.Diagnostics
+		p.modpack.LoadingMods = false
+	}
+	p.mutex.Unlock()
+
+	events.publish(Event{Type: "modListLoaded", ID: p.id, Data: struct {
+		Mods        map[int]ModInfo
+		Diagnostics []Diagnostic
+	}{loadingPack.Mods, loadingPack.Diagnostics}})
+}
+
+// loadOpenModpacks opens the modpacks that were open when the cache was last written
+func loadOpenModpacks() {
+	for _, v := range mainCache.OpenModpacks {
+		loadedModpack := loadCachedModpack(v)
+		if loadedModpack == nil {
+			continue
+		}
+		pack, err := workspace.get(workspace.open(*loadedModpack))
+		if err != nil {
+			continue
+		}
+		// Don't wait for every mod list before the server starts
+		go pack.loadModInfoList()
+	}
+}
+
+func getCurrentPackDetails(w io.Writer, id string) {
+	// Fall back to the last opened modpack, for new tabs
+	if len(id) == 0 {
+		workspace.mutex.RLock()
+		id = workspace.lastOpened
+		workspace.mutex.RUnlock()
+	}
+
+	pack, err := workspace.get(id)
+	if err != nil { // Empty modpack
+		json.NewEncoder(w).Encode(struct {
+			Modpack []byte
+		}{nil})
+		return
+	}
+
+	pack.mutex.RLock()
+	defer pack.mutex.RUnlock()
+
+	// Send the modpack to the client
+	json.NewEncoder(w).Encode(struct {
+		ID      string
+		Modpack Modpack
+	}{pack.id, pack.modpack})
+}
+
+func listOpenModpacks(w io.Writer) {
+	json.NewEncoder(w).Encode(struct {
+		Modpacks []OpenModpackInfo
+	}{workspace.list()})
+}
+
+func closeModpack(w http.ResponseWriter, id string) {
+	if _, err := workspace.get(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	workspace.close(id)
+
+	json.NewEncoder(w).Encode(struct{}{})
+}